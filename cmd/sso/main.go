@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -23,15 +24,21 @@ func main() {
 
 	log.Info("starting application")
 
-	application := app.New(log, cfg.GRPC.Port, cfg.Database, cfg.GRPC.Timeout)
+	application := app.New(log, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	application.RunJobs(ctx)
 
 	go application.GRPCSrv.MustRun()
+	go application.HTTPSrv.MustRun()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
 	<-stop
 
+	cancel()
+	application.HTTPSrv.Stop()
 	application.GRPCSrv.Stop()
 
 	log.Info("application stopped")
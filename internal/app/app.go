@@ -1,42 +1,307 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"time"
 
 	grpcapp "github.com/ei-jobs/auth-service/internal/app/grpc"
+	httpapp "github.com/ei-jobs/auth-service/internal/app/http"
 	"github.com/ei-jobs/auth-service/internal/config"
+	"github.com/ei-jobs/auth-service/internal/lib/events"
+	"github.com/ei-jobs/auth-service/internal/lib/password"
+	"github.com/ei-jobs/auth-service/internal/lib/ratelimit"
+	"github.com/ei-jobs/auth-service/internal/lib/secretbox"
+	"github.com/ei-jobs/auth-service/internal/lib/sms"
+	"github.com/ei-jobs/auth-service/internal/lib/webhook"
+	apprepository "github.com/ei-jobs/auth-service/internal/repository/apps"
+	auditrepository "github.com/ei-jobs/auth-service/internal/repository/audit"
 	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+	keyrepository "github.com/ei-jobs/auth-service/internal/repository/keys"
+	lockoutrepository "github.com/ei-jobs/auth-service/internal/repository/lockout"
+	otprepository "github.com/ei-jobs/auth-service/internal/repository/otp"
+	outboxrepository "github.com/ei-jobs/auth-service/internal/repository/outbox"
+	ratelimitrepository "github.com/ei-jobs/auth-service/internal/repository/ratelimit"
+	rbacrepository "github.com/ei-jobs/auth-service/internal/repository/rbac"
+	userrepository "github.com/ei-jobs/auth-service/internal/repository/user"
+	webhookrepository "github.com/ei-jobs/auth-service/internal/repository/webhook"
+	appservice "github.com/ei-jobs/auth-service/internal/service/apps"
+	auditservice "github.com/ei-jobs/auth-service/internal/service/audit"
 	service "github.com/ei-jobs/auth-service/internal/service/auth"
+	keyservice "github.com/ei-jobs/auth-service/internal/service/keys"
+	lockoutservice "github.com/ei-jobs/auth-service/internal/service/lockout"
+	otpservice "github.com/ei-jobs/auth-service/internal/service/otp"
+	outboxservice "github.com/ei-jobs/auth-service/internal/service/outbox"
+	rbacservice "github.com/ei-jobs/auth-service/internal/service/rbac"
+	userservice "github.com/ei-jobs/auth-service/internal/service/user"
+	webhookservice "github.com/ei-jobs/auth-service/internal/service/webhook"
 	_ "github.com/lib/pq"
 )
 
 type App struct {
 	GRPCSrv *grpcapp.App
+	HTTPSrv *httpapp.App
+	jobs    []func(ctx context.Context)
 }
 
-func New(log *slog.Logger, grpcPort int, cfg config.DatabaseConfig, tokenTTL time.Duration) *App {
-    connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/postgres?sslmode=%s",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.SSLMode)
+func New(log *slog.Logger, cfg *config.Config) *App {
+	dbCfg := cfg.Database
+
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/postgres?sslmode=%s",
+		dbCfg.User, dbCfg.Password, dbCfg.Host, dbCfg.Port, dbCfg.SSLMode)
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-        panic(err)
+		panic(err)
 	}
 	defer db.Close()
 
 	connStr = fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name, cfg.SSLMode)
+		dbCfg.User, dbCfg.Password, dbCfg.Host, dbCfg.Port, dbCfg.Name, dbCfg.SSLMode)
 	db, err = sql.Open("postgres", connStr)
 
 	authRepository := repository.NewAuthRepository(db)
+	appRepository := apprepository.NewAppRepository(db)
+	keyRepository := keyrepository.NewKeyRepository(db)
+	otpRepository := otprepository.NewOTPRepository(db)
+	rbacRepository := rbacrepository.NewRBACRepository(db)
+	lockoutRepository := lockoutrepository.NewLockoutRepository(db)
+	userRepository := userrepository.NewUserRepository(db)
+	auditRepository := auditrepository.NewAuditRepository(db)
+	outboxRepository := outboxrepository.NewOutboxRepository(db)
+	webhookRepository := webhookrepository.NewWebhookRepository(db)
+
+	secrets, err := secretbox.New(cfg.Apps.SecretKey)
+	if err != nil {
+		panic(err)
+	}
+
+	appService := appservice.NewAppService(log, appRepository, secrets, cfg.Apps.SecretGracePeriod)
+	keyService := keyservice.NewKeyService(
+		log,
+		keyRepository,
+		secrets,
+		cfg.Signing.Algorithm,
+		cfg.Signing.PerApp,
+		cfg.Signing.RotationInterval,
+		cfg.Signing.RetentionPeriod,
+	)
+	otpService := otpservice.NewOTPService(
+		log,
+		otpRepository,
+		newSMSSender(log, cfg.SMS),
+		cfg.OTP.Length,
+		cfg.OTP.TTL,
+		cfg.OTP.MaxAttempts,
+		cfg.OTP.ResendInterval,
+	)
+	rbacService := rbacservice.NewRBACService(log, rbacRepository)
+	auditService := auditservice.NewAuditService(log, auditRepository)
+	userService := userservice.NewUserService(log, userRepository, auditService, cfg.Users.RestorePeriod, cfg.Users.PurgeMode)
+	lockoutService := lockoutservice.NewLockoutService(log, lockoutRepository, lockoutservice.Policy{
+		MaxAttempts:      cfg.Lockout.MaxAttempts,
+		IPMaxAttempts:    cfg.Lockout.IPMaxAttempts,
+		DisableIPLockout: cfg.Lockout.DisableIPLockout,
+		Window:           cfg.Lockout.Window,
+		BaseDelay:        cfg.Lockout.BaseDelay,
+		MaxDelay:         cfg.Lockout.MaxDelay,
+		LockDuration:     cfg.Lockout.Duration,
+	})
+	hasher, err := password.NewHasher(
+		cfg.Password.Algorithm,
+		cfg.Password.BcryptCost,
+		password.Argon2Params{
+			Memory:      cfg.Password.Argon2.Memory,
+			Iterations:  cfg.Password.Argon2.Iterations,
+			Parallelism: cfg.Password.Argon2.Parallelism,
+			SaltLength:  cfg.Password.Argon2.SaltLength,
+			KeyLength:   cfg.Password.Argon2.KeyLength,
+		},
+		cfg.Password.Pepper,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordPolicy := password.Policy{
+		MinLength:          cfg.Password.MinLength,
+		RequireUppercase:   cfg.Password.RequireUppercase,
+		RequireLowercase:   cfg.Password.RequireLowercase,
+		RequireDigit:       cfg.Password.RequireDigit,
+		RequireSymbol:      cfg.Password.RequireSymbol,
+		ForbidPersonalInfo: cfg.Password.ForbidPersonalInfo,
+	}
+	if cfg.Password.BreachedListPath != "" {
+		breached, err := password.LoadBreachedList(cfg.Password.BreachedListPath)
+		if err != nil {
+			panic(err)
+		}
+		log.Info("loaded breached password list", slog.Int("entries", breached.Len()))
+		passwordPolicy.Breached = breached
+	}
+
+	authService := service.NewAuthService(
+		log,
+		authRepository,
+		appService,
+		keyService,
+		otpService,
+		rbacService,
+		lockoutService,
+		hasher,
+		auditService,
+		passwordPolicy,
+		cfg.Token.AccessTTL,
+		cfg.Token.RefreshTTL,
+		cfg.Token.CompactRBAC,
+	)
+
+	webhookService := webhookservice.NewWebhookService(
+		log,
+		webhookRepository,
+		secrets,
+		webhook.NewSender(&http.Client{
+			// Every connection is checked against the address the host
+			// resolved to. No proxy is used, as the check would then only
+			// see the proxy.
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout:   30 * time.Second,
+					KeepAlive: 30 * time.Second,
+					Control:   webhook.DialControl,
+				}).DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// Redirects are not followed, so a delivery only counts once the
+			// subscribed URL itself accepts it.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}),
+		cfg.Webhooks.PollInterval,
+		cfg.Webhooks.BatchSize,
+		cfg.Webhooks.MaxAttempts,
+		cfg.Webhooks.Timeout,
+		cfg.Webhooks.AllowHTTP,
+	)
 
-	authService := service.NewAuthService(log, authRepository)
+	outboxService := outboxservice.NewOutboxService(
+		log,
+		outboxRepository,
+		events.Fanout{newEventPublisher(log, cfg.Outbox), webhookService},
+		cfg.Outbox.PollInterval,
+		cfg.Outbox.BatchSize,
+		cfg.Outbox.Retention,
+	)
 
-	grpcApp := grpcapp.NewApp(log, grpcPort, authService)
+	limiter := ratelimit.NewLimiter(newRateLimitStore(cfg.RateLimit, db))
+
+	trustedProxies, err := grpcapp.ParseTrustedProxies(cfg.GRPC.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
+	grpcApp := grpcapp.NewApp(
+		log,
+		cfg.GRPC.Port,
+		authService,
+		userService,
+		rbacService,
+		appService,
+		auditService,
+		webhookService,
+		cfg.GRPC.ServiceCredentials,
+		trustedProxies,
+		limiter,
+		cfg.RateLimit.Policies,
+	)
+	httpApp := httpapp.NewApp(log, cfg.HTTP.Port, keyService)
 
 	return &App{
 		GRPCSrv: grpcApp,
+		HTTPSrv: httpApp,
+		jobs: []func(ctx context.Context){
+			keyService.RunRotation,
+			lockoutService.RunCleanup,
+			userService.RunPurge,
+			auditService.RunVerification,
+			outboxService.RunRelay,
+			webhookService.RunDelivery,
+			runRateLimitCleanup(log, limiter, cfg.RateLimit.Policies),
+		},
+	}
+}
+
+func newSMSSender(log *slog.Logger, cfg config.SMSConfig) otpservice.SMSSender {
+	switch cfg.Driver {
+	case "log":
+		return sms.NewLogSender(log)
+	case "file":
+		return sms.NewFileSender(cfg.FilePath)
+	default:
+		panic("unknown sms driver: " + cfg.Driver)
+	}
+}
+
+func newEventPublisher(log *slog.Logger, cfg config.OutboxConfig) events.Publisher {
+	switch cfg.Publisher {
+	case "log":
+		return events.NewLogPublisher(log)
+	case "file":
+		return events.NewFilePublisher(cfg.FilePath)
+	default:
+		panic("unknown event publisher: " + cfg.Publisher)
+	}
+}
+
+func newRateLimitStore(cfg config.RateLimitConfig, db *sql.DB) ratelimit.Store {
+	switch cfg.Store {
+	case "memory":
+		return ratelimit.NewMemoryStore()
+	case "postgres":
+		return ratelimitrepository.NewRateLimitRepository(db)
+	default:
+		panic("unknown rate limit store: " + cfg.Store)
+	}
+}
+
+// runRateLimitCleanup drops rate limit counters that no policy window can
+// reach any more.
+func runRateLimitCleanup(log *slog.Logger, limiter *ratelimit.Limiter, policies []config.RateLimitPolicy) func(ctx context.Context) {
+	var maxWindow time.Duration
+	for _, policy := range policies {
+		if policy.Window > maxWindow {
+			maxWindow = policy.Window
+		}
+	}
+
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := limiter.Cleanup(ctx, maxWindow); err != nil {
+				log.Error("failed to clean up rate limit counters", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunJobs starts the background jobs; they stop when ctx is cancelled.
+func (a *App) RunJobs(ctx context.Context) {
+	for _, job := range a.jobs {
+		go job(ctx)
 	}
 }
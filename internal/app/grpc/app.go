@@ -4,8 +4,16 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/netip"
 
+	"github.com/ei-jobs/auth-service/internal/config"
+	appsgrpc "github.com/ei-jobs/auth-service/internal/grpc/apps"
+	auditgrpc "github.com/ei-jobs/auth-service/internal/grpc/audit"
 	authgrpc "github.com/ei-jobs/auth-service/internal/grpc/auth"
+	rbacgrpc "github.com/ei-jobs/auth-service/internal/grpc/rbac"
+	usergrpc "github.com/ei-jobs/auth-service/internal/grpc/user"
+	webhookgrpc "github.com/ei-jobs/auth-service/internal/grpc/webhook"
+	"github.com/ei-jobs/auth-service/internal/lib/ratelimit"
 	"google.golang.org/grpc"
 )
 
@@ -15,10 +23,23 @@ type App struct {
 	port       int
 }
 
-func NewApp(log *slog.Logger, port int, auth authgrpc.AuthService) *App {
-	gRPCServer := grpc.NewServer()
+func NewApp(log *slog.Logger, port int, auth authgrpc.AuthService, users usergrpc.UserService, rbac rbacgrpc.RBACService, apps appsgrpc.AppService, audit auditgrpc.AuditService, webhooks webhookgrpc.WebhookService, serviceCredentials []config.ServiceCredential, trustedProxies []netip.Prefix, limiter *ratelimit.Limiter, rateLimits []config.RateLimitPolicy) *App {
+	gRPCServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			errorLogInterceptor(log),
+			requestInfoInterceptor(trustedProxies),
+			serviceAuthInterceptor(serviceCredentials),
+			rateLimitInterceptor(log, limiter, rateLimits),
+			authInterceptor(auth),
+		),
+	)
 
 	authgrpc.RegisterServerAPI(gRPCServer, auth)
+	usergrpc.RegisterUserAPI(gRPCServer, users)
+	rbacgrpc.RegisterServerAPI(gRPCServer, rbac)
+	appsgrpc.RegisterServerAPI(gRPCServer, apps)
+	auditgrpc.RegisterAuditAPI(gRPCServer, audit)
+	webhookgrpc.RegisterWebhookAPI(gRPCServer, webhooks)
 
 	return &App{
 		log:        log,
@@ -0,0 +1,104 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/ei-jobs/auth-service/internal/config"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*jwt.Claims, error)
+}
+
+// serviceAuthInterceptor resolves a configured service token in the
+// authorization metadata into a service principal.Principal stored in the
+// request context. It runs before rate limiting, which services are exempt
+// from, and leaves every other credential to authInterceptor.
+func serviceAuthInterceptor(services []config.ServiceCredential) grpc.UnaryServerInterceptor {
+	serviceDigests := make(map[string][sha256.Size]byte, len(services))
+	for _, svc := range services {
+		serviceDigests[svc.Name] = sha256.Sum256([]byte(svc.Token))
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if token, ok := bearerToken(ctx); ok {
+			if name, ok := matchService(serviceDigests, token); ok {
+				return handler(principal.WithPrincipal(ctx, &principal.Principal{Service: name}), req)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// authInterceptor resolves a user access token in the authorization
+// metadata into a principal.Principal stored in the request context. The
+// token is verified against its app's signing key and session, which is
+// why it runs after rate limiting: floods of invalid tokens are limited
+// before they cost any lookups. Requests without credentials pass through
+// unauthenticated, and each handler decides whether it needs a principal.
+func authInterceptor(validator TokenValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := principal.FromContext(ctx); ok {
+			return handler(ctx, req)
+		}
+
+		token, ok := bearerToken(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		claims, err := validator.ValidateToken(ctx, token)
+		if err != nil {
+			return nil, grpcerr.Status(err)
+		}
+
+		return handler(principal.WithPrincipal(ctx, &principal.Principal{
+			UserId:      claims.Uid,
+			AppId:       claims.AppId,
+			SessionId:   claims.SessionId,
+			Admin:       claims.Admin,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+		}), req)
+	}
+}
+
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", false
+	}
+
+	scheme, token, found := strings.Cut(values[0], " ")
+	if !found || !strings.EqualFold(scheme, "bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+func matchService(digests map[string][sha256.Size]byte, token string) (string, bool) {
+	digest := sha256.Sum256([]byte(token))
+
+	for name, expected := range digests {
+		if subtle.ConstantTimeCompare(digest[:], expected[:]) == 1 {
+			return name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,25 @@
+package grpcapp
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"google.golang.org/grpc"
+)
+
+// errorLogInterceptor logs the errors that handlers report as Internal.
+// The client only sees "internal error", so this is the one place the
+// underlying error and its op chain are recorded.
+func errorLogInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if cause, ok := grpcerr.Cause(err); ok {
+			log.Error("request failed",
+				slog.String("method", info.FullMethod),
+				slog.String("error", cause.Error()),
+			)
+		}
+		return resp, err
+	}
+}
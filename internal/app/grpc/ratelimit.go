@@ -0,0 +1,126 @@
+package grpcapp
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/config"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	"github.com/ei-jobs/auth-service/internal/lib/ratelimit"
+	"github.com/ei-jobs/auth-service/internal/lib/requestinfo"
+	"google.golang.org/grpc"
+)
+
+const (
+	rateLimitKeyIP    = "ip"
+	rateLimitKeyPhone = "phone"
+	rateLimitKeyAppId = "app_id"
+)
+
+type phoneRequest interface {
+	GetPhone() string
+}
+
+type appRequest interface {
+	GetAppId() int32
+}
+
+// rateLimitInterceptor applies every configured policy whose method matches
+// the call. A policy counts hits per distinct combination of its keys, so
+// keys [ip] limit each client while [phone, app_id] limit each account
+// whatever address it is tried from. Services are not limited, and store
+// errors let the call through rather than failing it.
+func rateLimitInterceptor(log *slog.Logger, limiter *ratelimit.Limiter, policies []config.RateLimitPolicy) grpc.UnaryServerInterceptor {
+	for _, policy := range policies {
+		for _, key := range policy.Keys {
+			switch key {
+			case rateLimitKeyIP, rateLimitKeyPhone, rateLimitKeyAppId:
+			default:
+				panic("unknown rate limit key: " + key)
+			}
+		}
+		if policy.Limit <= 0 || policy.Window <= 0 {
+			panic("rate limit policy for " + policy.Method + " needs a positive limit and window")
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if p, ok := principal.FromContext(ctx); ok && p.IsService() {
+			return handler(ctx, req)
+		}
+
+		for i, policy := range policies {
+			if !matchMethod(policy.Method, info.FullMethod) {
+				continue
+			}
+
+			key := rateLimitKey(ctx, i, info.FullMethod, policy.Keys, req)
+
+			allowed, retryAfter, err := limiter.Allow(ctx, key, policy.Limit, policy.Window)
+			if err != nil {
+				log.Error("failed to check rate limit",
+					slog.String("method", info.FullMethod),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+
+			if !allowed {
+				return nil, rateLimitedStatus(retryAfter)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// matchMethod accepts "*", a full method name such as "/auth.Auth/Login",
+// or a bare RPC name such as "Login".
+func matchMethod(pattern string, fullMethod string) bool {
+	if pattern == "*" || pattern == fullMethod {
+		return true
+	}
+
+	if strings.Contains(pattern, "/") {
+		return false
+	}
+
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:] == pattern
+}
+
+func rateLimitKey(ctx context.Context, policy int, method string, keys []string, req interface{}) string {
+	var b strings.Builder
+
+	b.WriteString(strconv.Itoa(policy))
+	b.WriteString(method)
+
+	for _, key := range keys {
+		b.WriteString("|")
+		b.WriteString(key)
+		b.WriteString("=")
+
+		switch key {
+		case rateLimitKeyIP:
+			b.WriteString(requestinfo.FromContext(ctx).IP)
+		case rateLimitKeyPhone:
+			if r, ok := req.(phoneRequest); ok {
+				b.WriteString(r.GetPhone())
+			}
+		case rateLimitKeyAppId:
+			if r, ok := req.(appRequest); ok {
+				b.WriteString(strconv.Itoa(int(r.GetAppId())))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func rateLimitedStatus(retryAfter time.Duration) error {
+	return grpcerr.Status(apperr.ErrRateLimited.WithMessage("rate limit exceeded, try again later").WithRetryAfter(retryAfter))
+}
@@ -0,0 +1,104 @@
+package grpcapp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/ei-jobs/auth-service/internal/lib/requestinfo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ParseTrustedProxies parses the addresses of proxies whose forwarding
+// headers are trusted. Each entry is an IP address or a CIDR prefix.
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	const op = "grpcapp.ParseTrustedProxies"
+
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// requestInfoInterceptor records the client address and user agent. The
+// address is the one of the TCP peer, unless the peer is a trusted proxy:
+// then X-Forwarded-For is followed back to the first address that is not.
+func requestInfoInterceptor(trustedProxies []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var ri requestinfo.Info
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			ri.IP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(ri.IP); err == nil {
+				ri.IP = host
+			}
+			ri.IP = clientIP(ri.IP, md.Get("x-forwarded-for"), trustedProxies)
+		}
+
+		if values := md.Get("user-agent"); len(values) > 0 {
+			ri.UserAgent = values[0]
+		}
+
+		return handler(requestinfo.WithInfo(ctx, ri), req)
+	}
+}
+
+// clientIP walks X-Forwarded-For from the right, where the nearest proxy
+// appended its peer, for as long as the hops are trusted proxies. Entries
+// left of the first untrusted hop could be set by the client and are
+// ignored, as is the header of a peer that is not trusted.
+func clientIP(peerIP string, forwardedFor []string, trustedProxies []netip.Prefix) string {
+	ip := peerIP
+
+	var hops []string
+	for _, value := range forwardedFor {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && trusted(ip, trustedProxies); i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = addr.Unmap().String()
+	}
+
+	return ip
+}
+
+func trusted(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package grpcapp
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		peer         string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name: "untrusted peer without header",
+			peer: "203.0.113.7",
+			want: "203.0.113.7",
+		},
+		{
+			name:         "untrusted peer header ignored",
+			peer:         "203.0.113.7",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "trusted peer",
+			peer:         "10.1.2.3",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "client-set entries left of the first untrusted hop",
+			peer:         "10.1.2.3",
+			forwardedFor: []string{"1.1.1.1, 198.51.100.1, 192.168.1.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "header split across values",
+			peer:         "10.1.2.3",
+			forwardedFor: []string{"1.1.1.1", "198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "only trusted hops",
+			peer:         "10.1.2.3",
+			forwardedFor: []string{"10.9.9.9, 192.168.1.1"},
+			want:         "10.9.9.9",
+		},
+		{
+			name:         "malformed hop",
+			peer:         "10.1.2.3",
+			forwardedFor: []string{"198.51.100.1, garbage"},
+			want:         "10.1.2.3",
+		},
+		{
+			name: "trusted peer without header",
+			peer: "10.1.2.3",
+			want: "10.1.2.3",
+		},
+		{
+			name:         "ipv6 proxy",
+			peer:         "fd12::1",
+			forwardedFor: []string{"2001:db8::5"},
+			want:         "2001:db8::5",
+		},
+		{
+			name:         "ipv4-mapped peer",
+			peer:         "::ffff:10.1.2.3",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(tt.peer, tt.forwardedFor, trustedProxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, entry := range []string{"proxy.local", "10.0.0.0/33", ""} {
+		if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) error = nil", entry)
+		}
+	}
+}
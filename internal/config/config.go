@@ -9,14 +9,179 @@ import (
 )
 
 type Config struct {
-	Env      string         `yaml:"env" env-default:"local"`
-	GRPC     GRPCConfig     `yaml:"grpc"`
-	Database DatabaseConfig `yaml:"database"`
+	Env       string          `yaml:"env" env-default:"local"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Token     TokenConfig     `yaml:"token"`
+	Signing   SigningConfig   `yaml:"signing"`
+	Password  PasswordConfig  `yaml:"password"`
+	OTP       OTPConfig       `yaml:"otp"`
+	SMS       SMSConfig       `yaml:"sms"`
+	Apps      AppsConfig      `yaml:"apps"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Users     UsersConfig     `yaml:"users"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
 }
 
+// GRPCConfig configures the gRPC server. TrustedProxies lists the IPs and
+// CIDR prefixes of proxies whose X-Forwarded-For header is believed. Without
+// it, the client address is the TCP peer, which behind a proxy is the proxy
+// itself for every client; set lockout.disable_ip_lockout in that case.
 type GRPCConfig struct {
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Port               int                 `yaml:"port"`
+	Timeout            time.Duration       `yaml:"timeout"`
+	ServiceCredentials []ServiceCredential `yaml:"service_credentials"`
+	TrustedProxies     []string            `yaml:"trusted_proxies"`
+}
+
+// ServiceCredential is a static bearer token that lets another service act
+// on any user.
+type ServiceCredential struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+}
+
+type HTTPConfig struct {
+	Port int `yaml:"port" env-default:"8080"`
+}
+
+// TokenConfig sets token lifetimes. CompactRBAC puts roles and permissions
+// into access tokens as space-separated strings rather than arrays.
+type TokenConfig struct {
+	AccessTTL   time.Duration `yaml:"access_ttl" env-default:"15m"`
+	RefreshTTL  time.Duration `yaml:"refresh_ttl" env-default:"720h"`
+	CompactRBAC bool          `yaml:"compact_rbac" env-default:"false"`
+}
+
+// SigningConfig selects how access tokens are signed. HS256 keeps signing
+// with the per-app secret; RS256, ES256 and EdDSA use keys from the
+// signing_keys table, global or per app, rotated every RotationInterval and
+// published for RetentionPeriod after retirement. RetentionPeriod must be
+// longer than the access token TTL.
+type SigningConfig struct {
+	Algorithm        string        `yaml:"algorithm" env-default:"RS256"`
+	PerApp           bool          `yaml:"per_app"`
+	RotationInterval time.Duration `yaml:"rotation_interval" env-default:"720h"`
+	RetentionPeriod  time.Duration `yaml:"retention_period" env-default:"168h"`
+}
+
+// PasswordConfig holds the password policy and selects how new passwords
+// are hashed, "argon2id" or "bcrypt". Hashes made with the other algorithm
+// or older parameters keep verifying and are replaced on the next login.
+// Pepper, when set, is mixed into every new hash and must not change
+// afterwards. BreachedListPath points to a file of breached passwords, one
+// per line, either plain or as SHA-1 hex digests.
+type PasswordConfig struct {
+	MinLength          int          `yaml:"min_length" env-default:"8"`
+	RequireUppercase   bool         `yaml:"require_uppercase"`
+	RequireLowercase   bool         `yaml:"require_lowercase"`
+	RequireDigit       bool         `yaml:"require_digit"`
+	RequireSymbol      bool         `yaml:"require_symbol"`
+	ForbidPersonalInfo bool         `yaml:"forbid_personal_info" env-default:"true"`
+	BreachedListPath   string       `yaml:"breached_list_path"`
+	Algorithm          string       `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost         int          `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2             Argon2Config `yaml:"argon2"`
+	Pepper             string       `yaml:"pepper" env:"PASSWORD_PEPPER"`
+}
+
+// Argon2Config holds the argon2id parameters; Memory is in KiB.
+type Argon2Config struct {
+	Memory      uint32 `yaml:"memory" env-default:"65536"`
+	Iterations  uint32 `yaml:"iterations" env-default:"3"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+	SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength   uint32 `yaml:"key_length" env-default:"32"`
+}
+
+type OTPConfig struct {
+	Length         int           `yaml:"length" env-default:"6"`
+	TTL            time.Duration `yaml:"ttl" env-default:"5m"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"5"`
+	ResendInterval time.Duration `yaml:"resend_interval" env-default:"1m"`
+}
+
+// SMSConfig selects the SMS sender: "log" writes messages to the service log
+// and "file" appends them to FilePath.
+type SMSConfig struct {
+	Driver   string `yaml:"driver" env-default:"log"`
+	FilePath string `yaml:"file_path" env-default:"sms.log"`
+}
+
+// AppsConfig holds the master key that encrypts app secrets, webhook
+// secrets and signing keys at rest, a base64-encoded 32-byte AES key, and
+// how long a rotated secret keeps verifying tokens by default. The key
+// usually comes from APP_SECRET_KEY; the service does not start without one.
+type AppsConfig struct {
+	SecretKey         string        `yaml:"secret_key" env:"APP_SECRET_KEY" env-required:"true"`
+	SecretGracePeriod time.Duration `yaml:"secret_grace_period" env-default:"24h"`
+}
+
+// LockoutConfig throttles failed logins. Each failure within Window delays
+// the next attempt by BaseDelay, doubling up to MaxDelay, and MaxAttempts
+// failures per account (IPMaxAttempts per client IP) lock it for Duration.
+// DisableIPLockout turns the per-IP counting off, for deployments where
+// client addresses are not known; see GRPCConfig.TrustedProxies.
+type LockoutConfig struct {
+	MaxAttempts      int           `yaml:"max_attempts" env-default:"5"`
+	IPMaxAttempts    int           `yaml:"ip_max_attempts" env-default:"50"`
+	DisableIPLockout bool          `yaml:"disable_ip_lockout" env-default:"false"`
+	Window           time.Duration `yaml:"window" env-default:"15m"`
+	BaseDelay        time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay         time.Duration `yaml:"max_delay" env-default:"1m"`
+	Duration         time.Duration `yaml:"duration" env-default:"15m"`
+}
+
+// RateLimitConfig keeps counters in "memory", per replica, or in
+// "postgres", shared by all replicas.
+type RateLimitConfig struct {
+	Store    string            `yaml:"store" env-default:"memory"`
+	Policies []RateLimitPolicy `yaml:"policies"`
+}
+
+// RateLimitPolicy allows Limit calls of Method per Window for each distinct
+// combination of Keys, which are any of "ip", "phone" and "app_id". Method
+// is "*", a full gRPC method name or a bare RPC name.
+type RateLimitPolicy struct {
+	Method string        `yaml:"method"`
+	Keys   []string      `yaml:"keys"`
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// UsersConfig sets how long deleted users can be restored and what happens
+// to them afterwards: "anonymize" scrubs their personal data but keeps the
+// row, "delete" removes it.
+type UsersConfig struct {
+	RestorePeriod time.Duration `yaml:"restore_period" env-default:"720h"`
+	PurgeMode     string        `yaml:"purge_mode" env-default:"anonymize"`
+}
+
+// OutboxConfig selects how domain events are published: "log" writes them
+// to the service log and "file" appends them to FilePath as JSON lines. The
+// relay polls every PollInterval for up to BatchSize events and keeps
+// published events for Retention.
+type OutboxConfig struct {
+	Publisher    string        `yaml:"publisher" env-default:"log"`
+	FilePath     string        `yaml:"file_path" env-default:"events.log"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Retention    time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+// WebhooksConfig controls webhook delivery. Due deliveries are polled for
+// every PollInterval, up to BatchSize at a time, and each request may take
+// up to Timeout. A delivery is dead after MaxAttempts failures. AllowHTTP
+// accepts plain http URLs, for local development only.
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"50"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"12"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	AllowHTTP    bool          `yaml:"allow_http" env-default:"false"`
 }
 
 type DatabaseConfig struct {
@@ -0,0 +1,127 @@
+// Package apperr defines the errors that services and repositories return
+// to callers. Every Error has a Kind, which decides the gRPC status code,
+// and a stable Reason that clients can match on.
+package apperr
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+type Kind int
+
+const (
+	Internal Kind = iota
+	InvalidArgument
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	Unauthenticated
+	FailedPrecondition
+	ResourceExhausted
+)
+
+// FieldViolation describes what is wrong with one request field.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is a typed error. Errors match with errors.Is when their reasons are
+// equal, so copies made by the With methods still match the sentinel they
+// were made from.
+type Error struct {
+	Kind    Kind
+	Reason  string
+	Message string
+	// Fields lists invalid request fields of InvalidArgument errors.
+	Fields []FieldViolation
+	// Metadata adds machine-readable details to Reason.
+	Metadata map[string]string
+	// RetryAfter tells the client how long to wait before retrying.
+	RetryAfter time.Duration
+}
+
+func New(kind Kind, reason string, message string) *Error {
+	return &Error{Kind: kind, Reason: reason, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Reason == e.Reason
+}
+
+// WithFields returns a copy with fields appended.
+func (e *Error) WithFields(fields ...FieldViolation) *Error {
+	c := e.clone()
+	c.Fields = append(c.Fields, fields...)
+	return c
+}
+
+// WithMetadata returns a copy with key set to value.
+func (e *Error) WithMetadata(key string, value string) *Error {
+	c := e.clone()
+	if c.Metadata == nil {
+		c.Metadata = make(map[string]string)
+	}
+	c.Metadata[key] = value
+	return c
+}
+
+// WithRetryAfter returns a copy that asks the client to wait d.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	c := e.clone()
+	c.RetryAfter = d
+	return c
+}
+
+// WithReason returns a copy with another reason. The copy no longer matches
+// e, which makes it a new sentinel.
+func (e *Error) WithReason(reason string) *Error {
+	c := e.clone()
+	c.Reason = reason
+	return c
+}
+
+// WithMessage returns a copy with another message.
+func (e *Error) WithMessage(message string) *Error {
+	c := e.clone()
+	c.Message = message
+	return c
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.Fields = slices.Clone(e.Fields)
+	c.Metadata = maps.Clone(e.Metadata)
+	return &c
+}
+
+// Errors raised by transport code rather than by a particular service.
+var (
+	ErrInvalidArgument  = New(InvalidArgument, "INVALID_ARGUMENT", "invalid argument")
+	ErrUnauthenticated  = New(Unauthenticated, "AUTHENTICATION_REQUIRED", "authentication required")
+	ErrPermissionDenied = New(PermissionDenied, "PERMISSION_DENIED", "permission denied")
+	ErrRateLimited      = New(ResourceExhausted, "RATE_LIMITED", "rate limit exceeded")
+)
+
+// Required reports a missing request field.
+func Required(field string) *Error {
+	return ErrInvalidArgument.WithMessage(field + " is required").WithFields(FieldViolation{
+		Field:       field,
+		Description: "is required",
+	})
+}
+
+// Invalid reports a request field with a bad value.
+func Invalid(field string, description string) *Error {
+	return ErrInvalidArgument.WithMessage(field + " " + description).WithFields(FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+}
@@ -1,7 +1,69 @@
 package model
 
+import "time"
+
+// App is a client of the auth service. Secret signs HS256 tokens; during a
+// rotation PreviousSecret keeps verifying them until PreviousSecretExpiresAt.
+// Secrets are stored encrypted and hold plaintext only once loaded through
+// the app service.
 type App struct {
-	Id     int
-	Name   string
-	Secret string
+	Id                      int
+	Name                    string
+	Secret                  string
+	PreviousSecret          *string
+	PreviousSecretExpiresAt *time.Time
+	SecretRotatedAt         *time.Time
+	AllowUnverifiedLogin    bool
+	Settings                AppSettings
+	DisabledAt              *time.Time
+	CreatedAt               time.Time
+}
+
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodPhoneCode = "phone_code"
+)
+
+// AppSettings overrides service defaults for one app. Zero values, nil
+// password rules and an empty LoginMethods fall back to the defaults, which
+// allow every method.
+type AppSettings struct {
+	AccessTTL         time.Duration
+	RefreshTTL        time.Duration
+	PasswordMinLength int
+	PasswordMaxLength int
+	LoginMethods      []string
+
+	PasswordRequireUppercase   *bool
+	PasswordRequireLowercase   *bool
+	PasswordRequireDigit       *bool
+	PasswordRequireSymbol      *bool
+	PasswordForbidPersonalInfo *bool
+	// PasswordCheckBreached can only turn the check off when the service has
+	// no breached list configured.
+	PasswordCheckBreached *bool
+}
+
+func (a *App) Disabled() bool {
+	return a.DisabledAt != nil
+}
+
+// PreviousSecretValid reports whether tokens signed with the previous secret
+// are still accepted at now.
+func (a *App) PreviousSecretValid(now time.Time) bool {
+	return a.PreviousSecret != nil && a.PreviousSecretExpiresAt != nil && now.Before(*a.PreviousSecretExpiresAt)
+}
+
+func (a *App) AllowsLoginMethod(method string) bool {
+	if len(a.Settings.LoginMethods) == 0 {
+		return true
+	}
+
+	for _, m := range a.Settings.LoginMethods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditEventComputeHash(t *testing.T) {
+	createdAt := time.Date(2026, 1, 1, 12, 0, 0, 123000000, time.UTC)
+	base := AuditEvent{
+		Id:           1,
+		Type:         AuditLogin,
+		AppId:        1,
+		ActorUserId:  7,
+		TargetUserId: 7,
+		IP:           "10.0.0.1",
+		UserAgent:    "grpc-go",
+		Metadata:     map[string]string{"method": "password", "session": "abc"},
+		CreatedAt:    createdAt,
+		PrevHash:     "prev",
+	}
+	want := base.ComputeHash()
+
+	tests := []struct {
+		name     string
+		modify   func(e *AuditEvent)
+		wantSame bool
+	}{
+		{name: "unchanged", modify: func(e *AuditEvent) {}, wantSame: true},
+		{name: "id is not hashed", modify: func(e *AuditEvent) { e.Id = 2 }, wantSame: true},
+		{name: "stored hash is not hashed", modify: func(e *AuditEvent) { e.Hash = "x" }, wantSame: true},
+		{name: "same instant in another zone", modify: func(e *AuditEvent) { e.CreatedAt = createdAt.In(time.FixedZone("UTC+5", 5*3600)) }, wantSame: true},
+		{name: "metadata rebuilt in another order", modify: func(e *AuditEvent) {
+			e.Metadata = map[string]string{"session": "abc", "method": "password"}
+		}, wantSame: true},
+		{name: "type", modify: func(e *AuditEvent) { e.Type = AuditLoginFailed }},
+		{name: "app", modify: func(e *AuditEvent) { e.AppId = 2 }},
+		{name: "actor", modify: func(e *AuditEvent) { e.ActorUserId = 8 }},
+		{name: "actor service", modify: func(e *AuditEvent) { e.ActorService = "billing" }},
+		{name: "target", modify: func(e *AuditEvent) { e.TargetUserId = 8 }},
+		{name: "ip", modify: func(e *AuditEvent) { e.IP = "10.0.0.2" }},
+		{name: "user agent", modify: func(e *AuditEvent) { e.UserAgent = "curl" }},
+		{name: "metadata value", modify: func(e *AuditEvent) { e.Metadata = map[string]string{"method": "otp", "session": "abc"} }},
+		{name: "created at", modify: func(e *AuditEvent) { e.CreatedAt = createdAt.Add(time.Microsecond) }},
+		{name: "previous hash", modify: func(e *AuditEvent) { e.PrevHash = "other" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := base
+			tt.modify(&event)
+
+			if got := event.ComputeHash(); (got == want) != tt.wantSame {
+				t.Errorf("ComputeHash() = %s, base %s, want same = %v", got, want, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestAuditEventComputeHashEmptyMetadata(t *testing.T) {
+	withNil := AuditEvent{Type: AuditLogin, CreatedAt: time.Unix(0, 0)}
+	withEmpty := withNil
+	withEmpty.Metadata = map[string]string{}
+
+	if withNil.ComputeHash() != withEmpty.ComputeHash() {
+		t.Error("nil and empty metadata hash differently")
+	}
+}
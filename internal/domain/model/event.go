@@ -0,0 +1,103 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain event types published to other services.
+const (
+	EventUserRegistered  = "user.registered"
+	EventUserUpdated     = "user.updated"
+	EventPasswordChanged = "user.password_changed"
+	EventPhoneVerified   = "user.phone_verified"
+	EventUserDeleted     = "user.deleted"
+	EventUserRestored    = "user.restored"
+	EventUserSuspended   = "user.suspended"
+	EventUserUnsuspended = "user.unsuspended"
+)
+
+// Event is a domain event about a user. It is stored in the outbox together
+// with the change it describes and published afterwards, at least once.
+type Event struct {
+	Id         int64
+	Type       string
+	AppId      int32
+	UserId     int64
+	Payload    json.RawMessage
+	OccurredAt time.Time
+	Attempts   int
+}
+
+// UserPayload is the payload of user events. Credentials never leave the
+// service; PasswordChanged and UserDeleted only carry the ids.
+type UserPayload struct {
+	UserId         int64      `json:"user_id"`
+	AppId          int32      `json:"app_id"`
+	Name           string     `json:"name,omitempty"`
+	Phone          string     `json:"phone,omitempty"`
+	AvatarUrl      *string    `json:"avatar_url,omitempty"`
+	Description    *string    `json:"description,omitempty"`
+	PhoneVerified  bool       `json:"phone_verified,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// NewUserEvent builds an event of type about user. Events that describe the
+// user's state carry the profile, the others only the ids.
+func NewUserEvent(eventType string, user User, occurredAt time.Time) Event {
+	payload := UserPayload{UserId: user.Id, AppId: user.AppId}
+
+	switch eventType {
+	case EventUserRegistered, EventUserUpdated, EventUserRestored, EventPhoneVerified:
+		payload.Name = user.Name
+		payload.Phone = user.Phone
+		payload.AvatarUrl = user.AvatarUrl
+		payload.Description = user.Description
+		payload.PhoneVerified = user.PhoneVerified()
+	case EventUserSuspended:
+		payload.SuspendedUntil = user.SuspendedUntil
+	}
+
+	// UserPayload always marshals.
+	raw, _ := json.Marshal(payload)
+
+	return Event{
+		Type:       eventType,
+		AppId:      user.AppId,
+		UserId:     user.Id,
+		Payload:    raw,
+		OccurredAt: occurredAt,
+	}
+}
+
+// IsEventType reports whether t is one of the event types above.
+func IsEventType(t string) bool {
+	switch t {
+	case EventUserRegistered, EventUserUpdated, EventPasswordChanged, EventPhoneVerified,
+		EventUserDeleted, EventUserRestored, EventUserSuspended, EventUserUnsuspended:
+		return true
+	}
+	return false
+}
+
+// EventEnvelope is how an event is serialised for consumers outside the
+// service. Id identifies the event across redeliveries.
+type EventEnvelope struct {
+	Id         int64           `json:"id"`
+	Type       string          `json:"type"`
+	AppId      int32           `json:"app_id"`
+	UserId     int64           `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+func (e Event) Envelope() EventEnvelope {
+	return EventEnvelope{
+		Id:         e.Id,
+		Type:       e.Type,
+		AppId:      e.AppId,
+		UserId:     e.UserId,
+		OccurredAt: e.OccurredAt,
+		Payload:    e.Payload,
+	}
+}
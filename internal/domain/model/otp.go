@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+const (
+	OTPPurposePasswordReset     = "password_reset"
+	OTPPurposePhoneVerification = "phone_verification"
+	OTPPurposeLogin             = "login"
+)
+
+type OTPCode struct {
+	Id         int64
+	Phone      string
+	AppId      int32
+	Purpose    string
+	CodeHash   []byte
+	Attempts   int
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
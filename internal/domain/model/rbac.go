@@ -0,0 +1,16 @@
+package model
+
+type Role struct {
+	Id          int64
+	AppId       int32
+	Name        string
+	Description *string
+	Permissions []string
+}
+
+type Permission struct {
+	Id          int64
+	AppId       int32
+	Name        string
+	Description *string
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type Session struct {
+	Id         string
+	UserId     int64
+	AppId      int32
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
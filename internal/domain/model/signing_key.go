@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// SigningKey is an asymmetric token signing key. A nil AppId marks a global
+// key shared by all apps. Retired keys no longer sign tokens but stay
+// published for verification until ExpiresAt. PrivateKey is stored sealed.
+type SigningKey struct {
+	Id         int64
+	Kid        string
+	AppId      *int32
+	Algorithm  string
+	PrivateKey string
+	PublicKey  string
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+	ExpiresAt  *time.Time
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type RefreshToken struct {
+	Id        int64
+	TokenHash string
+	SessionId string
+	UserId    int64
+	AppId     int32
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
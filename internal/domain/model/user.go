@@ -1,12 +1,56 @@
 package model
 
+import "time"
+
 type User struct {
-	Id       int64
-	Phone    string
-	Name     string
-	PassHash []byte
-    AvatarUrl *string
-    Description *string
-    Balance int
-	AppId    int32
+	Id              int64
+	Phone           string
+	Name            string
+	PassHash        []byte
+	AvatarUrl       *string
+	Description     *string
+	Balance         int
+	AppId           int32
+	PhoneVerifiedAt *time.Time
+	IsAdmin         bool
+	CreatedAt       time.Time
+	Roles           []string
+	Permissions     []string
+
+	// SuspendedAt is set while the user is suspended. SuspendedUntil is nil
+	// for an indefinite suspension, and SuspendedBy is nil when a service
+	// rather than an admin suspended the user.
+	SuspendedAt      *time.Time
+	SuspendedUntil   *time.Time
+	SuspensionReason *string
+	SuspendedBy      *int64
+}
+
+func (u *User) PhoneVerified() bool {
+	return u.PhoneVerifiedAt != nil
+}
+
+// Suspended reports whether a suspension is in force at now. Suspensions
+// lapse on their own once SuspendedUntil has passed.
+func (u *User) Suspended(now time.Time) bool {
+	return u.SuspendedAt != nil && (u.SuspendedUntil == nil || now.Before(*u.SuspendedUntil))
+}
+
+// UserFilter narrows a user listing to one app. Zero fields do not filter.
+// Query matches names and phones approximately.
+type UserFilter struct {
+	AppId         int32
+	Query         string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	PhoneVerified *bool
+	Role          string
+}
+
+// UserPage is one page of a user listing. NextPageToken is empty on the
+// last page and TotalCount counts every user matching the filter.
+type UserPage struct {
+	Users         []User
+	NextPageToken string
+	TotalCount    int64
 }
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses. A delivery is pending until it succeeds or
+// runs out of attempts, which leaves it dead until it is replayed.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription sends the app's events of EventTypes, or of every
+// type if it is empty, to URL. Secret signs the requests.
+type WebhookSubscription struct {
+	Id         int64
+	AppId      int32
+	URL        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is one event to be sent to one subscription. Payload is
+// the request body. Subscription is only loaded for claimed deliveries.
+type WebhookDelivery struct {
+	Id             int64
+	SubscriptionId int64
+	EventId        int64
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      *string
+	LastStatusCode *int
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+	Subscription   *WebhookSubscription
+}
@@ -0,0 +1,312 @@
+package appsgrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	ssov1 "github.com/ei-jobs/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type AppService interface {
+	CreateApp(ctx context.Context, name string, allowUnverifiedLogin bool) (model.App, error)
+	GetApp(ctx context.Context, appId int32) (model.App, error)
+	ListApps(ctx context.Context, includeDisabled bool) ([]model.App, error)
+	UpdateApp(ctx context.Context, appId int32, name string, allowUnverifiedLogin bool) (model.App, error)
+	DisableApp(ctx context.Context, appId int32) (model.App, error)
+	EnableApp(ctx context.Context, appId int32) (model.App, error)
+	DeleteApp(ctx context.Context, appId int32) error
+	RotateSecret(ctx context.Context, appId int32, gracePeriod time.Duration) (model.App, error)
+	UpdateSettings(ctx context.Context, appId int32, settings model.AppSettings, requirePhoneVerification bool) (model.App, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAppServiceServer
+	apps AppService
+}
+
+func RegisterServerAPI(gRPC *grpc.Server, apps AppService) {
+	ssov1.RegisterAppServiceServer(gRPC, &serverAPI{apps: apps})
+}
+
+// CreateApp returns the generated secret once; it cannot be read back later.
+func (s *serverAPI) CreateApp(ctx context.Context, req *ssov1.CreateAppRequest) (*ssov1.CreateAppResponse, error) {
+	if govalidator.IsNull(req.GetName()) {
+		return nil, grpcerr.Status(apperr.Required("name"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.CreateApp(ctx, req.GetName(), req.GetAllowUnverifiedLogin())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.CreateAppResponse{
+		App:    toApp(app),
+		Secret: app.Secret,
+	}, nil
+}
+
+func (s *serverAPI) GetApp(ctx context.Context, req *ssov1.GetAppRequest) (*ssov1.GetAppResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.GetApp(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.GetAppResponse{
+		App: toApp(app),
+	}, nil
+}
+
+func (s *serverAPI) ListApps(ctx context.Context, req *ssov1.ListAppsRequest) (*ssov1.ListAppsResponse, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	apps, err := s.apps.ListApps(ctx, req.GetIncludeDisabled())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &ssov1.ListAppsResponse{
+		Apps: make([]*ssov1.App, 0, len(apps)),
+	}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, toApp(app))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) UpdateApp(ctx context.Context, req *ssov1.UpdateAppRequest) (*ssov1.UpdateAppResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetName()) {
+		return nil, grpcerr.Status(apperr.Required("name"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.UpdateApp(ctx, req.GetAppId(), req.GetName(), req.GetAllowUnverifiedLogin())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.UpdateAppResponse{
+		App: toApp(app),
+	}, nil
+}
+
+func (s *serverAPI) DisableApp(ctx context.Context, req *ssov1.DisableAppRequest) (*ssov1.DisableAppResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.DisableApp(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.DisableAppResponse{
+		App: toApp(app),
+	}, nil
+}
+
+func (s *serverAPI) EnableApp(ctx context.Context, req *ssov1.EnableAppRequest) (*ssov1.EnableAppResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.EnableApp(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.EnableAppResponse{
+		App: toApp(app),
+	}, nil
+}
+
+func (s *serverAPI) DeleteApp(ctx context.Context, req *ssov1.DeleteAppRequest) (*ssov1.DeleteAppResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.apps.DeleteApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.DeleteAppResponse{
+		Success: true,
+	}, nil
+}
+
+// RotateAppSecret returns the new secret once. Tokens signed with the old one
+// keep verifying for grace_period_seconds, or the configured default.
+func (s *serverAPI) RotateAppSecret(ctx context.Context, req *ssov1.RotateAppSecretRequest) (*ssov1.RotateAppSecretResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetGracePeriodSeconds() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("grace_period_seconds", "must not be negative"))
+	}
+
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	gracePeriod := time.Duration(req.GetGracePeriodSeconds()) * time.Second
+
+	app, err := s.apps.RotateSecret(ctx, req.GetAppId(), gracePeriod)
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.RotateAppSecretResponse{
+		App:    toApp(app),
+		Secret: app.Secret,
+	}, nil
+}
+
+// GetAppSettings and UpdateAppSettings are also open to admins of the app.
+func (s *serverAPI) GetAppSettings(ctx context.Context, req *ssov1.GetAppSettingsRequest) (*ssov1.GetAppSettingsResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	app, err := s.apps.GetApp(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.GetAppSettingsResponse{
+		Settings: toSettings(app),
+	}, nil
+}
+
+func (s *serverAPI) UpdateAppSettings(ctx context.Context, req *ssov1.UpdateAppSettingsRequest) (*ssov1.UpdateAppSettingsResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetSettings() == nil {
+		return nil, grpcerr.Status(apperr.Required("settings"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	settings := req.GetSettings()
+
+	app, err := s.apps.UpdateSettings(ctx, req.GetAppId(), model.AppSettings{
+		AccessTTL:         time.Duration(settings.GetAccessTtlSeconds()) * time.Second,
+		RefreshTTL:        time.Duration(settings.GetRefreshTtlSeconds()) * time.Second,
+		PasswordMinLength: int(settings.GetPasswordMinLength()),
+		PasswordMaxLength: int(settings.GetPasswordMaxLength()),
+		LoginMethods:      settings.GetLoginMethods(),
+
+		PasswordRequireUppercase:   settings.PasswordRequireUppercase,
+		PasswordRequireLowercase:   settings.PasswordRequireLowercase,
+		PasswordRequireDigit:       settings.PasswordRequireDigit,
+		PasswordRequireSymbol:      settings.PasswordRequireSymbol,
+		PasswordForbidPersonalInfo: settings.PasswordForbidPersonalInfo,
+		PasswordCheckBreached:      settings.PasswordCheckBreached,
+	}, settings.GetRequirePhoneVerification())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.UpdateAppSettingsResponse{
+		Settings: toSettings(app),
+	}, nil
+}
+
+// authorize admits only services: admin users are scoped to a single app
+// and may not manage apps.
+func authorize(ctx context.Context) error {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	if !p.IsService() {
+		return grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("only services may manage apps"))
+	}
+
+	return nil
+}
+
+func toApp(app model.App) *ssov1.App {
+	resp := &ssov1.App{
+		Id:                   int32(app.Id),
+		Name:                 app.Name,
+		AllowUnverifiedLogin: app.AllowUnverifiedLogin,
+		Disabled:             app.Disabled(),
+		CreatedAt:            timestamppb.New(app.CreatedAt),
+	}
+	if app.SecretRotatedAt != nil {
+		resp.SecretRotatedAt = timestamppb.New(*app.SecretRotatedAt)
+	}
+	if app.PreviousSecretExpiresAt != nil {
+		resp.PreviousSecretExpiresAt = timestamppb.New(*app.PreviousSecretExpiresAt)
+	}
+	return resp
+}
+
+// toSettings reports the stored overrides; zero or unset means the service
+// default.
+func toSettings(app model.App) *ssov1.AppSettings {
+	return &ssov1.AppSettings{
+		AccessTtlSeconds:           int64(app.Settings.AccessTTL / time.Second),
+		RefreshTtlSeconds:          int64(app.Settings.RefreshTTL / time.Second),
+		PasswordMinLength:          int32(app.Settings.PasswordMinLength),
+		PasswordMaxLength:          int32(app.Settings.PasswordMaxLength),
+		LoginMethods:               app.Settings.LoginMethods,
+		RequirePhoneVerification:   !app.AllowUnverifiedLogin,
+		PasswordRequireUppercase:   app.Settings.PasswordRequireUppercase,
+		PasswordRequireLowercase:   app.Settings.PasswordRequireLowercase,
+		PasswordRequireDigit:       app.Settings.PasswordRequireDigit,
+		PasswordRequireSymbol:      app.Settings.PasswordRequireSymbol,
+		PasswordForbidPersonalInfo: app.Settings.PasswordForbidPersonalInfo,
+		PasswordCheckBreached:      app.Settings.PasswordCheckBreached,
+	}
+}
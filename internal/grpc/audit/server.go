@@ -0,0 +1,97 @@
+package auditgrpc
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	auditv1 "github.com/ei-jobs/protos/gen/go/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type AuditService interface {
+	ListEvents(ctx context.Context, filter model.AuditFilter, pageSize int, pageToken string) ([]model.AuditEvent, string, error)
+}
+
+type auditAPI struct {
+	auditv1.UnimplementedAuditServiceServer
+	service AuditService
+}
+
+func RegisterAuditAPI(gRPC *grpc.Server, service AuditService) {
+	auditv1.RegisterAuditServiceServer(gRPC, &auditAPI{service: service})
+}
+
+// ListAuditEvents is for services and admins of the app. Events carry their
+// hashes, so that callers can check the chain themselves.
+func (s *auditAPI) ListAuditEvents(ctx context.Context, req *auditv1.ListAuditEventsRequest) (*auditv1.ListAuditEventsResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetPageSize() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("page_size", "must not be negative"))
+	}
+
+	filter := model.AuditFilter{
+		AppId:        req.GetAppId(),
+		Type:         req.GetType(),
+		ActorUserId:  req.GetActorUserId(),
+		TargetUserId: req.GetTargetUserId(),
+	}
+
+	if ts := req.GetCreatedAfter(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, grpcerr.Status(apperr.Invalid("created_after", "is not a valid timestamp"))
+		}
+		t := ts.AsTime()
+		filter.CreatedAfter = &t
+	}
+
+	if ts := req.GetCreatedBefore(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, grpcerr.Status(apperr.Invalid("created_before", "is not a valid timestamp"))
+		}
+		t := ts.AsTime()
+		filter.CreatedBefore = &t
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	events, nextPageToken, err := s.service.ListEvents(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &auditv1.ListAuditEventsResponse{
+		NextPageToken: nextPageToken,
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toEvent(event))
+	}
+
+	return resp, nil
+}
+
+func toEvent(event model.AuditEvent) *auditv1.AuditEvent {
+	return &auditv1.AuditEvent{
+		Id:           event.Id,
+		Type:         event.Type,
+		AppId:        event.AppId,
+		ActorUserId:  event.ActorUserId,
+		ActorService: event.ActorService,
+		TargetUserId: event.TargetUserId,
+		Ip:           event.IP,
+		UserAgent:    event.UserAgent,
+		Metadata:     event.Metadata,
+		CreatedAt:    timestamppb.New(event.CreatedAt),
+		PrevHash:     event.PrevHash,
+		Hash:         event.Hash,
+	}
+}
@@ -2,22 +2,42 @@ package authgrpc
 
 import (
 	"context"
+	"errors"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	appservice "github.com/ei-jobs/auth-service/internal/service/apps"
+	service "github.com/ei-jobs/auth-service/internal/service/auth"
+	"github.com/ei-jobs/auth-service/pkg/jwk"
 	ssov1 "github.com/ei-jobs/protos/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type AuthService interface {
-	Login(ctx context.Context, phone string, password string, appId int32) (token string, err error)
-	Register(ctx context.Context, name string, phone string, password string, appId int32) (token string, err error)
-    UpdateUser(ctx context.Context, user *ssov1.User) (*ssov1.User, error)
-    GetUser(ctx context.Context, user_id int64) (*ssov1.User, error)
-    DeleteUser(ctx context.Context, user_id int64) (bool, error)
-	ForgetPassword(ctx context.Context, phone string, password string, app_id int32) (token string, err error)
-	ChangePassword(ctx context.Context, phone string, password string, app_id int32) (token string, err error)
+	Login(ctx context.Context, phone string, password string, appId int32) (tokens model.TokenPair, err error)
+	Register(ctx context.Context, name string, phone string, password string, appId int32) (tokens model.TokenPair, verificationRequired bool, err error)
+	SendPhoneVerification(ctx context.Context, phone string, appId int32) error
+	VerifyPhone(ctx context.Context, phone string, appId int32, code string) (tokens model.TokenPair, err error)
+	SendLoginCode(ctx context.Context, phone string, appId int32) error
+	LoginWithCode(ctx context.Context, phone string, appId int32, code string) (tokens model.TokenPair, err error)
+	RequestPasswordReset(ctx context.Context, phone string, appId int32) error
+	ConfirmPasswordReset(ctx context.Context, phone string, appId int32, code string, password string) (tokens model.TokenPair, err error)
+	ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string) (tokens model.TokenPair, err error)
+	RefreshToken(ctx context.Context, refreshToken string, appId int32) (tokens model.TokenPair, err error)
+	Logout(ctx context.Context, token string) error
+	LogoutAll(ctx context.Context, token string) (revoked int64, err error)
+	ListSessions(ctx context.Context, token string) (sessions []model.Session, currentId string, err error)
+	RevokeSession(ctx context.Context, token string, sessionId string) error
+	ValidateToken(ctx context.Context, token string) (claims *jwt.Claims, err error)
+	JWKS(ctx context.Context, appId int32) (jwk.Set, error)
+	UnlockAccount(ctx context.Context, appId int32, userId int64) (bool, error)
 }
 
 type serverAPI struct {
@@ -31,152 +51,407 @@ func RegisterServerAPI(gRPC *grpc.Server, auth AuthService) {
 
 func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
 	if govalidator.IsNull(req.GetPhone()) {
-		return nil, status.Error(codes.InvalidArgument, "phone is required")
+		return nil, grpcerr.Status(apperr.Required("phone"))
 	}
 
 	if govalidator.IsNull(req.GetPassword()) {
-		return nil, status.Error(codes.InvalidArgument, "password is required")
+		return nil, grpcerr.Status(apperr.Required("password"))
 	}
 
 	if !govalidator.IsPositive(float64(req.GetAppId())) {
-		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+		return nil, grpcerr.Status(apperr.Required("app_id"))
 	}
 
-	token, err := s.auth.Login(ctx, req.GetPhone(), req.GetPassword(), req.GetAppId())
+	tokens, err := s.auth.Login(ctx, req.GetPhone(), req.GetPassword(), req.GetAppId())
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid phone or password")
+		return nil, toStatus(err)
 	}
 
 	return &ssov1.LoginResponse{
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
 func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
 	if govalidator.IsNull(req.GetName()) {
-		return nil, status.Error(codes.InvalidArgument, "name is required")
+		return nil, grpcerr.Status(apperr.Required("name"))
 	}
 
 	if govalidator.IsNull(req.GetPhone()) {
-		return nil, status.Error(codes.InvalidArgument, "phone is required")
+		return nil, grpcerr.Status(apperr.Required("phone"))
 	}
 
 	if govalidator.IsNull(req.GetPassword()) {
-		return nil, status.Error(codes.InvalidArgument, "password is required")
+		return nil, grpcerr.Status(apperr.Required("password"))
 	}
 
 	if !govalidator.IsPositive(float64(req.GetAppId())) {
-		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+		return nil, grpcerr.Status(apperr.Required("app_id"))
 	}
 
-	token, err := s.auth.Register(ctx, req.GetName(), req.GetPhone(), req.GetPassword(), req.GetAppId())
+	tokens, verificationRequired, err := s.auth.Register(ctx, req.GetName(), req.GetPhone(), req.GetPassword(), req.GetAppId())
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
 	return &ssov1.RegisterResponse{
-		Token: token,
+		Token:                     tokens.AccessToken,
+		RefreshToken:              tokens.RefreshToken,
+		PhoneVerificationRequired: verificationRequired,
 	}, nil
 }
 
-func (s *serverAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
+func (s *serverAPI) SendPhoneVerification(ctx context.Context, req *ssov1.SendPhoneVerificationRequest) (*ssov1.SendPhoneVerificationResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetPhone()) {
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if err := s.auth.SendPhoneVerification(ctx, req.GetPhone(), req.GetAppId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.SendPhoneVerificationResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) VerifyPhone(ctx context.Context, req *ssov1.VerifyPhoneRequest) (*ssov1.VerifyPhoneResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetPhone()) {
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if govalidator.IsNull(req.GetCode()) {
+		return nil, grpcerr.Status(apperr.Required("code"))
+	}
+
+	tokens, err := s.auth.VerifyPhone(ctx, req.GetPhone(), req.GetAppId(), req.GetCode())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.VerifyPhoneResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *serverAPI) SendLoginCode(ctx context.Context, req *ssov1.SendLoginCodeRequest) (*ssov1.SendLoginCodeResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetPhone()) {
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if err := s.auth.SendLoginCode(ctx, req.GetPhone(), req.GetAppId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.SendLoginCodeResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) LoginWithCode(ctx context.Context, req *ssov1.LoginWithCodeRequest) (*ssov1.LoginWithCodeResponse, error) {
 	if !govalidator.IsPositive(float64(req.GetAppId())) {
-		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+		return nil, grpcerr.Status(apperr.Required("app_id"))
 	}
 
 	if govalidator.IsNull(req.GetPhone()) {
-		return nil, status.Error(codes.InvalidArgument, "phone is required")
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if govalidator.IsNull(req.GetCode()) {
+		return nil, grpcerr.Status(apperr.Required("code"))
+	}
+
+	tokens, err := s.auth.LoginWithCode(ctx, req.GetPhone(), req.GetAppId(), req.GetCode())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.LoginWithCodeResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *serverAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
 	}
 
 	if govalidator.IsNull(req.GetOldPassword()) {
-		return nil, status.Error(codes.InvalidArgument, "old password is required")
+		return nil, grpcerr.Status(apperr.Required("old_password"))
 	}
 
-	token, err := s.auth.ChangePassword(ctx, req.Phone, req.GetOldPassword(), req.GetAppId())
+	if govalidator.IsNull(req.GetNewPassword()) {
+		return nil, grpcerr.Status(apperr.Required("new_password"))
+	}
+
+	tokens, err := s.auth.ChangePassword(ctx, req.GetToken(), req.GetOldPassword(), req.GetNewPassword())
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
 	return &ssov1.ChangePasswordResponse{
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
+// ForgetPassword used to reset the password without any proof of owning the
+// phone. It is kept only to point old clients at the two-step flow.
 func (s *serverAPI) ForgetPassword(ctx context.Context, req *ssov1.ForgetPasswordRequest) (*ssov1.ForgetPasswordResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "use RequestPasswordReset and ConfirmPasswordReset")
+}
+
+func (s *serverAPI) RequestPasswordReset(ctx context.Context, req *ssov1.RequestPasswordResetRequest) (*ssov1.RequestPasswordResetResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetPhone()) {
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if err := s.auth.RequestPasswordReset(ctx, req.GetPhone(), req.GetAppId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.RequestPasswordResetResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) ConfirmPasswordReset(ctx context.Context, req *ssov1.ConfirmPasswordResetRequest) (*ssov1.ConfirmPasswordResetResponse, error) {
 	if !govalidator.IsPositive(float64(req.GetAppId())) {
-		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+		return nil, grpcerr.Status(apperr.Required("app_id"))
 	}
 
 	if govalidator.IsNull(req.GetPhone()) {
-		return nil, status.Error(codes.InvalidArgument, "phone is required")
+		return nil, grpcerr.Status(apperr.Required("phone"))
+	}
+
+	if govalidator.IsNull(req.GetCode()) {
+		return nil, grpcerr.Status(apperr.Required("code"))
 	}
 
 	if govalidator.IsNull(req.GetNewPassword()) {
-		return nil, status.Error(codes.InvalidArgument, "password is required")
+		return nil, grpcerr.Status(apperr.Required("new_password"))
 	}
 
-	token, err := s.auth.ForgetPassword(ctx, req.GetPhone(), req.GetNewPassword(), req.GetAppId())
+	tokens, err := s.auth.ConfirmPasswordReset(ctx, req.GetPhone(), req.GetAppId(), req.GetCode(), req.GetNewPassword())
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
-	return &ssov1.ForgetPasswordResponse{
-		Token: token,
+	return &ssov1.ConfirmPasswordResetResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
-func (s *serverAPI) UpdateUser(ctx context.Context, req *ssov1.UpdateUserRequest) (*ssov1.UpdateUserResponse, error) {
-    if govalidator.IsNull(req.GetUser().GetName()) {
-		return nil, status.Error(codes.InvalidArgument, "name is required")
+func (s *serverAPI) RefreshToken(ctx context.Context, req *ssov1.RefreshTokenRequest) (*ssov1.RefreshTokenResponse, error) {
+	if govalidator.IsNull(req.GetRefreshToken()) {
+		return nil, grpcerr.Status(apperr.Required("refresh_token"))
 	}
-    
-    if govalidator.IsNull(req.GetUser().GetPhone()) {
-		return nil, status.Error(codes.InvalidArgument, "phone is required")
+
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
 	}
 
-    if !govalidator.IsPositive(float64(req.GetUser().GetId())) {
-		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	tokens, err := s.auth.RefreshToken(ctx, req.GetRefreshToken(), req.GetAppId())
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-    if !govalidator.IsPositive(float64(req.GetUser().GetAppId())) {
-		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	return &ssov1.RefreshTokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *serverAPI) Logout(ctx context.Context, req *ssov1.LogoutRequest) (*ssov1.LogoutResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
+	}
+
+	if err := s.auth.Logout(ctx, req.GetToken()); err != nil {
+		return nil, toStatus(err)
 	}
 
-    user, err := s.auth.UpdateUser(ctx, req.GetUser())
-    if err != nil {
-        return nil, status.Error(codes.Internal, "internal error")
-    }
-    return &ssov1.UpdateUserResponse{
-        User: user,
-    }, err
+	return &ssov1.LogoutResponse{
+		Success: true,
+	}, nil
 }
 
-func (s *serverAPI) GetUser(ctx context.Context, req *ssov1.GetUserRequest) (*ssov1.GetUserResponse, error) {
-    if !govalidator.IsPositive(float64(req.GetUserId())) {
-		return nil, status.Error(codes.InvalidArgument, "user id is required")
+func (s *serverAPI) LogoutAll(ctx context.Context, req *ssov1.LogoutAllRequest) (*ssov1.LogoutAllResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
 	}
-    
-    user, err := s.auth.GetUser(ctx, req.GetUserId())
-    if err != nil {
-        return nil, status.Error(codes.Internal, err.Error()) 
-    }
 
-    return &ssov1.GetUserResponse{
-        User: user,
-    }, nil
+	revoked, err := s.auth.LogoutAll(ctx, req.GetToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.LogoutAllResponse{
+		RevokedCount: revoked,
+	}, nil
 }
 
-func (s *serverAPI) DeleteUser(ctx context.Context, req *ssov1.DeleteUserRequest) (*ssov1.DeleteUserResponse, error) {
-    if !govalidator.IsPositive(float64(req.GetUserId())) {
-		return nil, status.Error(codes.InvalidArgument, "user id is required")
+func (s *serverAPI) ListSessions(ctx context.Context, req *ssov1.ListSessionsRequest) (*ssov1.ListSessionsResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
 	}
-    
-    is_deleted, err := s.auth.DeleteUser(ctx, req.GetUserId())
-    if err != nil {
-        return nil, status.Error(codes.Internal, "internal error")
-    }
 
-    return &ssov1.DeleteUserResponse{
-        IsDeleted: is_deleted,
-    }, nil
+	sessions, currentId, err := s.auth.ListSessions(ctx, req.GetToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &ssov1.ListSessionsResponse{
+		Sessions: make([]*ssov1.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &ssov1.Session{
+			Id:         session.Id,
+			CreatedAt:  timestamppb.New(session.CreatedAt),
+			LastUsedAt: timestamppb.New(session.LastUsedAt),
+			ExpiresAt:  timestamppb.New(session.ExpiresAt),
+			Current:    session.Id == currentId,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeSession(ctx context.Context, req *ssov1.RevokeSessionRequest) (*ssov1.RevokeSessionResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
+	}
+
+	if govalidator.IsNull(req.GetSessionId()) {
+		return nil, grpcerr.Status(apperr.Required("session_id"))
+	}
+
+	if err := s.auth.RevokeSession(ctx, req.GetToken(), req.GetSessionId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.RevokeSessionResponse{
+		Success: true,
+	}, nil
+}
+
+// ValidateToken follows RFC 7662: a token that is not valid is reported as
+// inactive with no other fields set, and is not an error.
+func (s *serverAPI) ValidateToken(ctx context.Context, req *ssov1.ValidateTokenRequest) (*ssov1.ValidateTokenResponse, error) {
+	if govalidator.IsNull(req.GetToken()) {
+		return nil, grpcerr.Status(apperr.Required("token"))
+	}
+
+	claims, err := s.auth.ValidateToken(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return &ssov1.ValidateTokenResponse{Active: false}, nil
+		}
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.ValidateTokenResponse{
+		Active:        true,
+		UserId:        claims.Uid,
+		AppId:         claims.AppId,
+		Phone:         claims.Phone,
+		PhoneVerified: claims.PhoneVerified,
+		SessionId:     claims.SessionId,
+		TokenId:       claims.Jti,
+		IssuedAt:      claims.IssuedAt.Unix(),
+		ExpiresAt:     claims.ExpiresAt.Unix(),
+		Roles:         claims.Roles,
+		Permissions:   claims.Permissions,
+	}, nil
+}
+
+func (s *serverAPI) GetJWKS(ctx context.Context, req *ssov1.GetJWKSRequest) (*ssov1.GetJWKSResponse, error) {
+	if req.GetAppId() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("app_id", "must not be negative"))
+	}
+
+	set, err := s.auth.JWKS(ctx, req.GetAppId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &ssov1.GetJWKSResponse{
+		Keys: make([]*ssov1.JWK, 0, len(set.Keys)),
+	}
+	for _, key := range set.Keys {
+		resp.Keys = append(resp.Keys, &ssov1.JWK{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+
+	return resp, nil
+}
+
+// UnlockAccount is for services and admins of the user's app.
+func (s *serverAPI) UnlockAccount(ctx context.Context, req *ssov1.UnlockAccountRequest) (*ssov1.UnlockAccountResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return nil, grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	if !p.CanManageApp(req.GetAppId()) {
+		return nil, grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("not allowed to manage this app"))
+	}
+
+	unlocked, err := s.auth.UnlockAccount(ctx, req.GetAppId(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ssov1.UnlockAccountResponse{
+		Success: unlocked,
+	}, nil
+}
+
+// errUnknownApp replaces ErrAppNotFound outside app management: a client
+// naming an unknown app is misconfigured, so it is reported like bad
+// credentials.
+var errUnknownApp = apperr.New(apperr.Unauthenticated, "UNKNOWN_APP", "unknown app")
+
+func toStatus(err error) error {
+	if errors.Is(err, appservice.ErrAppNotFound) {
+		return grpcerr.Status(errUnknownApp)
+	}
+	return grpcerr.Status(err)
 }
@@ -0,0 +1,114 @@
+// Package grpcerr turns errors returned by services into gRPC statuses.
+package grpcerr
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain is reported in the ErrorInfo of every status.
+const Domain = "auth-service"
+
+var codeByKind = map[apperr.Kind]codes.Code{
+	apperr.Internal:           codes.Internal,
+	apperr.InvalidArgument:    codes.InvalidArgument,
+	apperr.NotFound:           codes.NotFound,
+	apperr.AlreadyExists:      codes.AlreadyExists,
+	apperr.PermissionDenied:   codes.PermissionDenied,
+	apperr.Unauthenticated:    codes.Unauthenticated,
+	apperr.FailedPrecondition: codes.FailedPrecondition,
+	apperr.ResourceExhausted:  codes.ResourceExhausted,
+}
+
+// Status converts err into a gRPC status error. An *apperr.Error in the
+// chain becomes a status with its code and message, an ErrorInfo with its
+// reason, a BadRequest with its field violations and a RetryInfo when it
+// has a retry delay. Anything else is reported as Internal without leaking
+// its text; Cause recovers it for logging.
+func Status(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) {
+		switch {
+		case errors.Is(err, context.Canceled):
+			return status.Error(codes.Canceled, "request canceled")
+		case errors.Is(err, context.DeadlineExceeded):
+			return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+		default:
+			return &internalError{status: status.New(codes.Internal, "internal error"), cause: err}
+		}
+	}
+
+	code, ok := codeByKind[appErr.Kind]
+	if !ok || appErr.Kind == apperr.Internal {
+		code = codes.Internal
+	}
+
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason:   appErr.Reason,
+			Domain:   Domain,
+			Metadata: appErr.Metadata,
+		},
+	}
+
+	if len(appErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range appErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	if appErr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{
+			RetryDelay: durationpb.New(appErr.RetryAfter),
+		})
+	}
+
+	st, detailErr := status.New(code, appErr.Message).WithDetails(details...)
+	if detailErr != nil {
+		return status.Error(code, appErr.Message)
+	}
+
+	return st.Err()
+}
+
+// internalError is the Internal status of an unexpected error. It keeps the
+// error, with its op chain, so that it can be logged once the handler
+// returns.
+type internalError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e *internalError) Error() string {
+	return e.status.Err().Error()
+}
+
+func (e *internalError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// Cause returns the error that Status reported as Internal, if err is such
+// a status.
+func Cause(err error) (error, bool) {
+	var internal *internalError
+	if !errors.As(err, &internal) {
+		return nil, false
+	}
+	return internal.cause, true
+}
@@ -0,0 +1,137 @@
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    codes.Code
+		wantMessage string
+	}{
+		{name: "internal kind", err: apperr.New(apperr.Internal, "BROKEN", "broken"), wantCode: codes.Internal, wantMessage: "broken"},
+		{name: "invalid argument", err: apperr.Required("phone"), wantCode: codes.InvalidArgument, wantMessage: "phone is required"},
+		{name: "not found", err: apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found"), wantCode: codes.NotFound, wantMessage: "user not found"},
+		{name: "already exists", err: apperr.New(apperr.AlreadyExists, "USER_EXISTS", "user already exists"), wantCode: codes.AlreadyExists, wantMessage: "user already exists"},
+		{name: "permission denied", err: apperr.ErrPermissionDenied, wantCode: codes.PermissionDenied, wantMessage: "permission denied"},
+		{name: "unauthenticated", err: apperr.ErrUnauthenticated, wantCode: codes.Unauthenticated, wantMessage: "authentication required"},
+		{name: "failed precondition", err: apperr.New(apperr.FailedPrecondition, "PHONE_NOT_VERIFIED", "phone is not verified"), wantCode: codes.FailedPrecondition, wantMessage: "phone is not verified"},
+		{name: "resource exhausted", err: apperr.ErrRateLimited, wantCode: codes.ResourceExhausted, wantMessage: "rate limit exceeded"},
+		{name: "unknown kind", err: apperr.New(apperr.Kind(100), "ODD", "odd"), wantCode: codes.Internal, wantMessage: "odd"},
+		{name: "wrapped", err: fmt.Errorf("op: %w", apperr.ErrUnauthenticated), wantCode: codes.Unauthenticated, wantMessage: "authentication required"},
+		{name: "plain error hides its text", err: errors.New("pq: connection refused"), wantCode: codes.Internal, wantMessage: "internal error"},
+		{name: "canceled", err: fmt.Errorf("op: %w", context.Canceled), wantCode: codes.Canceled, wantMessage: "request canceled"},
+		{name: "deadline exceeded", err: fmt.Errorf("op: %w", context.DeadlineExceeded), wantCode: codes.DeadlineExceeded, wantMessage: "deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(Status(tt.err))
+			if !ok {
+				t.Fatalf("Status() did not return a status error")
+			}
+			if st.Code() != tt.wantCode {
+				t.Errorf("code = %v, want %v", st.Code(), tt.wantCode)
+			}
+			if st.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", st.Message(), tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestStatusNil(t *testing.T) {
+	if err := Status(nil); err != nil {
+		t.Fatalf("Status(nil) = %v, want nil", err)
+	}
+}
+
+func TestStatusDetails(t *testing.T) {
+	err := apperr.Invalid("password", "is too short").
+		WithReason("PASSWORD_POLICY_VIOLATION").
+		WithMetadata("rule", "min_length").
+		WithRetryAfter(3 * time.Second)
+
+	st, _ := status.FromError(Status(fmt.Errorf("op: %w", err)))
+
+	var (
+		info       *errdetails.ErrorInfo
+		badRequest *errdetails.BadRequest
+		retryInfo  *errdetails.RetryInfo
+	)
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.BadRequest:
+			badRequest = d
+		case *errdetails.RetryInfo:
+			retryInfo = d
+		}
+	}
+
+	if info == nil {
+		t.Fatal("no ErrorInfo")
+	}
+	if info.GetReason() != "PASSWORD_POLICY_VIOLATION" || info.GetDomain() != Domain {
+		t.Errorf("ErrorInfo = %s/%s, want PASSWORD_POLICY_VIOLATION/%s", info.GetReason(), info.GetDomain(), Domain)
+	}
+	if info.GetMetadata()["rule"] != "min_length" {
+		t.Errorf("ErrorInfo metadata = %v", info.GetMetadata())
+	}
+
+	if badRequest == nil || len(badRequest.GetFieldViolations()) != 1 {
+		t.Fatalf("BadRequest = %v, want one field violation", badRequest)
+	}
+	if v := badRequest.GetFieldViolations()[0]; v.GetField() != "password" || v.GetDescription() != "is too short" {
+		t.Errorf("field violation = %s %q", v.GetField(), v.GetDescription())
+	}
+
+	if retryInfo == nil || retryInfo.GetRetryDelay().AsDuration() != 3*time.Second {
+		t.Errorf("RetryInfo = %v, want 3s", retryInfo)
+	}
+}
+
+func TestStatusOmitsEmptyDetails(t *testing.T) {
+	st, _ := status.FromError(Status(apperr.ErrUnauthenticated))
+
+	for _, detail := range st.Details() {
+		switch detail.(type) {
+		case *errdetails.BadRequest, *errdetails.RetryInfo:
+			t.Errorf("unexpected detail %T", detail)
+		}
+	}
+}
+
+func TestCause(t *testing.T) {
+	cause := fmt.Errorf("authservice.Login: %w", errors.New("pq: connection refused"))
+
+	got, ok := Cause(Status(cause))
+	if !ok {
+		t.Fatal("Cause() found no cause")
+	}
+	if got != cause {
+		t.Errorf("Cause() = %v, want %v", got, cause)
+	}
+
+	for _, err := range []error{
+		Status(apperr.ErrUnauthenticated),
+		Status(apperr.New(apperr.Internal, "BROKEN", "broken")),
+		Status(context.Canceled),
+	} {
+		if _, ok := Cause(err); ok {
+			t.Errorf("Cause(%v) found a cause", err)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package rbacgrpc
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	ssov1 "github.com/ei-jobs/protos/gen/go/sso"
+	"google.golang.org/grpc"
+)
+
+type RBACService interface {
+	CreateRole(ctx context.Context, appId int32, name string, description string) (model.Role, error)
+	ListRoles(ctx context.Context, appId int32) ([]model.Role, error)
+	DeleteRole(ctx context.Context, appId int32, roleId int64) error
+	CreatePermission(ctx context.Context, appId int32, name string, description string) (model.Permission, error)
+	ListPermissions(ctx context.Context, appId int32) ([]model.Permission, error)
+	DeletePermission(ctx context.Context, appId int32, permissionId int64) error
+	GrantPermission(ctx context.Context, appId int32, roleId int64, permissionId int64) error
+	RevokePermission(ctx context.Context, appId int32, roleId int64, permissionId int64) (bool, error)
+	AssignRole(ctx context.Context, appId int32, userId int64, roleId int64) error
+	RevokeRole(ctx context.Context, appId int32, userId int64, roleId int64) (bool, error)
+	ListUserRoles(ctx context.Context, appId int32, userId int64) ([]model.Role, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedRBACServer
+	rbac RBACService
+}
+
+func RegisterServerAPI(gRPC *grpc.Server, rbac RBACService) {
+	ssov1.RegisterRBACServer(gRPC, &serverAPI{rbac: rbac})
+}
+
+func (s *serverAPI) CreateRole(ctx context.Context, req *ssov1.CreateRoleRequest) (*ssov1.CreateRoleResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetName()) {
+		return nil, grpcerr.Status(apperr.Required("name"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	role, err := s.rbac.CreateRole(ctx, req.GetAppId(), req.GetName(), req.GetDescription())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.CreateRoleResponse{
+		Role: toRole(role),
+	}, nil
+}
+
+func (s *serverAPI) ListRoles(ctx context.Context, req *ssov1.ListRolesRequest) (*ssov1.ListRolesResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	roles, err := s.rbac.ListRoles(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.ListRolesResponse{
+		Roles: toRoles(roles),
+	}, nil
+}
+
+func (s *serverAPI) DeleteRole(ctx context.Context, req *ssov1.DeleteRoleRequest) (*ssov1.DeleteRoleResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetRoleId())) {
+		return nil, grpcerr.Status(apperr.Required("role_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := s.rbac.DeleteRole(ctx, req.GetAppId(), req.GetRoleId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.DeleteRoleResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) CreatePermission(ctx context.Context, req *ssov1.CreatePermissionRequest) (*ssov1.CreatePermissionResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if govalidator.IsNull(req.GetName()) {
+		return nil, grpcerr.Status(apperr.Required("name"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	permission, err := s.rbac.CreatePermission(ctx, req.GetAppId(), req.GetName(), req.GetDescription())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.CreatePermissionResponse{
+		Permission: toPermission(permission),
+	}, nil
+}
+
+func (s *serverAPI) ListPermissions(ctx context.Context, req *ssov1.ListPermissionsRequest) (*ssov1.ListPermissionsResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	permissions, err := s.rbac.ListPermissions(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &ssov1.ListPermissionsResponse{
+		Permissions: make([]*ssov1.Permission, 0, len(permissions)),
+	}
+	for _, permission := range permissions {
+		resp.Permissions = append(resp.Permissions, toPermission(permission))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeletePermission(ctx context.Context, req *ssov1.DeletePermissionRequest) (*ssov1.DeletePermissionResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetPermissionId())) {
+		return nil, grpcerr.Status(apperr.Required("permission_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := s.rbac.DeletePermission(ctx, req.GetAppId(), req.GetPermissionId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.DeletePermissionResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) GrantPermission(ctx context.Context, req *ssov1.GrantPermissionRequest) (*ssov1.GrantPermissionResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetRoleId())) {
+		return nil, grpcerr.Status(apperr.Required("role_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetPermissionId())) {
+		return nil, grpcerr.Status(apperr.Required("permission_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := s.rbac.GrantPermission(ctx, req.GetAppId(), req.GetRoleId(), req.GetPermissionId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.GrantPermissionResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) RevokePermission(ctx context.Context, req *ssov1.RevokePermissionRequest) (*ssov1.RevokePermissionResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetRoleId())) {
+		return nil, grpcerr.Status(apperr.Required("role_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetPermissionId())) {
+		return nil, grpcerr.Status(apperr.Required("permission_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	revoked, err := s.rbac.RevokePermission(ctx, req.GetAppId(), req.GetRoleId(), req.GetPermissionId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.RevokePermissionResponse{
+		Success: revoked,
+	}, nil
+}
+
+func (s *serverAPI) AssignRole(ctx context.Context, req *ssov1.AssignRoleRequest) (*ssov1.AssignRoleResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetRoleId())) {
+		return nil, grpcerr.Status(apperr.Required("role_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := s.rbac.AssignRole(ctx, req.GetAppId(), req.GetUserId(), req.GetRoleId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.AssignRoleResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *serverAPI) RevokeRole(ctx context.Context, req *ssov1.RevokeRoleRequest) (*ssov1.RevokeRoleResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetRoleId())) {
+		return nil, grpcerr.Status(apperr.Required("role_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	revoked, err := s.rbac.RevokeRole(ctx, req.GetAppId(), req.GetUserId(), req.GetRoleId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.RevokeRoleResponse{
+		Success: revoked,
+	}, nil
+}
+
+// ListUserRoles is also open to users asking for their own roles.
+func (s *serverAPI) ListUserRoles(ctx context.Context, req *ssov1.ListUserRolesRequest) (*ssov1.ListUserRolesResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return nil, grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	self := p.UserId == req.GetUserId() && p.AppId == req.GetAppId()
+	if !self && !p.CanManageApp(req.GetAppId()) {
+		return nil, grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("not allowed to list roles of this user"))
+	}
+
+	roles, err := s.rbac.ListUserRoles(ctx, req.GetAppId(), req.GetUserId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &ssov1.ListUserRolesResponse{
+		Roles: toRoles(roles),
+	}, nil
+}
+
+func toRole(role model.Role) *ssov1.Role {
+	resp := &ssov1.Role{
+		Id:          role.Id,
+		AppId:       role.AppId,
+		Name:        role.Name,
+		Permissions: role.Permissions,
+	}
+	if role.Description != nil {
+		resp.Description = *role.Description
+	}
+	return resp
+}
+
+func toRoles(roles []model.Role) []*ssov1.Role {
+	resp := make([]*ssov1.Role, 0, len(roles))
+	for _, role := range roles {
+		resp = append(resp, toRole(role))
+	}
+	return resp
+}
+
+func toPermission(permission model.Permission) *ssov1.Permission {
+	resp := &ssov1.Permission{
+		Id:    permission.Id,
+		AppId: permission.AppId,
+		Name:  permission.Name,
+	}
+	if permission.Description != nil {
+		resp.Description = *permission.Description
+	}
+	return resp
+}
@@ -2,31 +2,435 @@ package usergrpc
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	service "github.com/ei-jobs/auth-service/internal/service/user"
 	userv1 "github.com/ei-jobs/protos/gen/go/user"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserService interface {
+	GetUser(ctx context.Context, userId int64) (model.User, error)
+	BatchGetUsers(ctx context.Context, appId int32, userIds []int64) (found map[int64]model.User, missing []int64, err error)
+	UpdateUser(ctx context.Context, userId int64, profile service.Profile) (model.User, error)
+	DeleteUser(ctx context.Context, userId int64) (bool, error)
+	RestoreUser(ctx context.Context, appId int32, userId int64) (model.User, error)
+	SuspendUser(ctx context.Context, appId int32, userId int64, suspension service.Suspension) (model.User, error)
+	UnsuspendUser(ctx context.Context, appId int32, userId int64) (model.User, error)
+	ListUsers(ctx context.Context, filter model.UserFilter, pageSize int, pageToken string) (model.UserPage, error)
 }
 
+const maxSuspensionReasonLength = 1000
+
 type userAPI struct {
-    userv1.UnimplementedUserServiceServer
-    service UserService
+	userv1.UnimplementedUserServiceServer
+	service UserService
 }
 
 func RegisterUserAPI(gRPC *grpc.Server, service UserService) {
-    userv1.RegisterUserServiceServer(gRPC, &userAPI{service: service})
+	userv1.RegisterUserServiceServer(gRPC, &userAPI{service: service})
 }
 
 func (s *userAPI) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
-    panic("implement me")
+	if !govalidator.IsPositive(float64(req.GetUser().GetId())) {
+		return nil, grpcerr.Status(apperr.Required("user.id"))
+	}
+
+	if govalidator.IsNull(req.GetUser().GetName()) {
+		return nil, grpcerr.Status(apperr.Required("user.name"))
+	}
+
+	if err := s.authorize(ctx, req.GetUser().GetId()); err != nil {
+		return nil, err
+	}
+
+	user, err := s.service.UpdateUser(ctx, req.GetUser().GetId(), service.Profile{
+		Name:        req.GetUser().GetName(),
+		AvatarUrl:   req.GetUser().GetAvatarUrl(),
+		Description: req.GetUser().GetDescription(),
+	})
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.UpdateUserResponse{
+		User: toUser(user),
+	}, nil
 }
 
 func (s *userAPI) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
-    panic("implement me")
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	if err := s.authorize(ctx, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	user, err := s.service.GetUser(ctx, req.GetUserId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.GetUserResponse{
+		User: toUser(user),
+	}, nil
+}
+
+// BatchGetUsers is for services, which may read users of any app, and for
+// admins, who only see users of their own app. Only the fields named in the
+// field mask are returned, or all of them without a mask.
+func (s *userAPI) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	if len(req.GetUserIds()) == 0 {
+		return nil, grpcerr.Status(apperr.Required("user_ids"))
+	}
+
+	for _, id := range req.GetUserIds() {
+		if id <= 0 {
+			return nil, grpcerr.Status(apperr.Invalid("user_ids", "must contain only positive ids"))
+		}
+	}
+
+	paths := req.GetFieldMask().GetPaths()
+	for _, path := range paths {
+		if !userFields[path] {
+			return nil, grpcerr.Status(apperr.Invalid("field_mask", fmt.Sprintf("has unknown path %q", path)))
+		}
+	}
+
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return nil, grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	var appId int32
+	switch {
+	case p.IsService():
+	case p.Admin:
+		appId = p.AppId
+	default:
+		return nil, grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("only services and admins may batch get users"))
+	}
+
+	found, missing, err := s.service.BatchGetUsers(ctx, appId, req.GetUserIds())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &userv1.BatchGetUsersResponse{
+		Users:      make(map[int64]*userv1.User, len(found)),
+		MissingIds: missing,
+	}
+	for id, user := range found {
+		resp.Users[id] = maskUser(toUser(user), paths)
+	}
+
+	return resp, nil
 }
 
 func (s *userAPI) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
-    panic("implement me")
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	if err := s.authorize(ctx, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.service.DeleteUser(ctx, req.GetUserId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.DeleteUserResponse{
+		IsDeleted: deleted,
+	}, nil
+}
+
+// SuspendUser is for services and admins, who only suspend users of their
+// own app. Admins are recorded as the moderator behind the suspension.
+func (s *userAPI) SuspendUser(ctx context.Context, req *userv1.SuspendUserRequest) (*userv1.SuspendUserResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	reason := strings.TrimSpace(req.GetReason())
+	if reason == "" {
+		return nil, grpcerr.Status(apperr.Required("reason"))
+	}
+	if len(reason) > maxSuspensionReasonLength {
+		return nil, grpcerr.Status(apperr.Invalid("reason", fmt.Sprintf("must not be longer than %d characters", maxSuspensionReasonLength)))
+	}
+
+	suspension := service.Suspension{Reason: reason}
+
+	if ts := req.GetExpiresAt(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, grpcerr.Status(apperr.Invalid("expires_at", "is not a valid timestamp"))
+		}
+		t := ts.AsTime()
+		suspension.Until = &t
+	}
+
+	p, appId, err := moderator(ctx, "suspend")
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.IsService() {
+		if p.UserId == req.GetUserId() {
+			return nil, grpcerr.Status(apperr.Invalid("user_id", "must not be your own account"))
+		}
+		suspension.By = p.UserId
+	}
+
+	user, err := s.service.SuspendUser(ctx, appId, req.GetUserId(), suspension)
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.SuspendUserResponse{
+		User: toUser(user),
+	}, nil
+}
+
+func (s *userAPI) UnsuspendUser(ctx context.Context, req *userv1.UnsuspendUserRequest) (*userv1.UnsuspendUserResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	_, appId, err := moderator(ctx, "unsuspend")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.service.UnsuspendUser(ctx, appId, req.GetUserId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.UnsuspendUserResponse{
+		User: toUser(user),
+	}, nil
+}
+
+// RestoreUser is for services, which may restore users of any app, and for
+// admins, who only restore users of their own app. Deleted users cannot
+// restore themselves, as their sessions are revoked.
+func (s *userAPI) RestoreUser(ctx context.Context, req *userv1.RestoreUserRequest) (*userv1.RestoreUserResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetUserId())) {
+		return nil, grpcerr.Status(apperr.Required("user_id"))
+	}
+
+	_, appId, err := moderator(ctx, "restore")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.service.RestoreUser(ctx, appId, req.GetUserId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &userv1.RestoreUserResponse{
+		User: toUser(user),
+	}, nil
+}
+
+// ListUsers is for services and admins of the app.
+func (s *userAPI) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetPageSize() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("page_size", "must not be negative"))
+	}
+
+	filter := model.UserFilter{
+		AppId:         req.GetAppId(),
+		Query:         strings.TrimSpace(req.GetQuery()),
+		PhoneVerified: req.PhoneVerified,
+		Role:          req.GetRole(),
+	}
+
+	if ts := req.GetCreatedAfter(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, grpcerr.Status(apperr.Invalid("created_after", "is not a valid timestamp"))
+		}
+		t := ts.AsTime()
+		filter.CreatedAfter = &t
+	}
+
+	if ts := req.GetCreatedBefore(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, grpcerr.Status(apperr.Invalid("created_before", "is not a valid timestamp"))
+		}
+		t := ts.AsTime()
+		filter.CreatedBefore = &t
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	page, err := s.service.ListUsers(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &userv1.ListUsersResponse{
+		NextPageToken: page.NextPageToken,
+		TotalCount:    page.TotalCount,
+	}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, toUser(user))
+	}
+
+	return resp, nil
+}
+
+// authorize lets users act only on their own account and admins only on
+// users of their own app, while services may act on any user. Users of
+// other apps are reported to admins as not found, like missing ones.
+func (s *userAPI) authorize(ctx context.Context, userId int64) error {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	if p.IsService() {
+		return nil
+	}
+
+	if !p.Admin {
+		if !p.CanActOn(userId, p.AppId) {
+			return grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("not allowed to act on this user"))
+		}
+		return nil
+	}
+
+	user, err := s.service.GetUser(ctx, userId)
+	if err != nil {
+		return grpcerr.Status(err)
+	}
+
+	if !p.CanActOn(userId, user.AppId) {
+		return grpcerr.Status(service.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// moderator admits services, which act on users of any app, and admins,
+// who act on users of their own app. appId is 0 for services. action names
+// what is refused to anyone else.
+func moderator(ctx context.Context, action string) (*principal.Principal, int32, error) {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return nil, 0, grpcerr.Status(apperr.ErrUnauthenticated)
+	}
+
+	switch {
+	case p.IsService():
+		return p, 0, nil
+	case p.Admin:
+		return p, p.AppId, nil
+	default:
+		return nil, 0, grpcerr.Status(apperr.ErrPermissionDenied.WithMessage("only services and admins may " + action + " users"))
+	}
+}
+
+// userFields are the field mask paths of userv1.User.
+var userFields = map[string]bool{
+	"id":             true,
+	"name":           true,
+	"phone":          true,
+	"app_id":         true,
+	"balance":        true,
+	"description":    true,
+	"avatar_url":     true,
+	"phone_verified": true,
+	"created_at":     true,
+	"suspension":     true,
+}
+
+// maskUser keeps the fields named in paths, and the id, which identifies
+// the user. No paths keep every field.
+func maskUser(user *userv1.User, paths []string) *userv1.User {
+	if len(paths) == 0 {
+		return user
+	}
+
+	masked := &userv1.User{Id: user.Id}
+	for _, path := range paths {
+		switch path {
+		case "name":
+			masked.Name = user.Name
+		case "phone":
+			masked.Phone = user.Phone
+		case "app_id":
+			masked.AppId = user.AppId
+		case "balance":
+			masked.Balance = user.Balance
+		case "description":
+			masked.Description = user.Description
+		case "avatar_url":
+			masked.AvatarUrl = user.AvatarUrl
+		case "phone_verified":
+			masked.PhoneVerified = user.PhoneVerified
+		case "created_at":
+			masked.CreatedAt = user.CreatedAt
+		case "suspension":
+			masked.Suspension = user.Suspension
+		}
+	}
+
+	return masked
+}
+
+func toUser(user model.User) *userv1.User {
+	resp := &userv1.User{
+		Id:            user.Id,
+		Name:          user.Name,
+		Phone:         user.Phone,
+		AppId:         user.AppId,
+		Balance:       int64(user.Balance),
+		PhoneVerified: user.PhoneVerified(),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+	}
+	if user.AvatarUrl != nil {
+		resp.AvatarUrl = *user.AvatarUrl
+	}
+	if user.Description != nil {
+		resp.Description = *user.Description
+	}
+	if user.Suspended(time.Now()) {
+		resp.Suspension = toSuspension(user)
+	}
+	return resp
+}
+
+func toSuspension(user model.User) *userv1.Suspension {
+	suspension := &userv1.Suspension{
+		SuspendedAt: timestamppb.New(*user.SuspendedAt),
+	}
+	if user.SuspensionReason != nil {
+		suspension.Reason = *user.SuspensionReason
+	}
+	if user.SuspendedBy != nil {
+		suspension.SuspendedBy = *user.SuspendedBy
+	}
+	if user.SuspendedUntil != nil {
+		suspension.SuspendedUntil = timestamppb.New(*user.SuspendedUntil)
+	}
+	return suspension
 }
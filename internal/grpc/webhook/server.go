@@ -0,0 +1,192 @@
+package webhookgrpc
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/grpc/grpcerr"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	webhookv1 "github.com/ei-jobs/protos/gen/go/webhook"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, appId int32, url string, eventTypes []string) (model.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context, appId int32) ([]model.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, appId int32, webhookId int64) error
+	ListDeliveries(ctx context.Context, appId int32, webhookId int64, status string, pageSize int, pageToken string) ([]model.WebhookDelivery, string, error)
+	ReplayDelivery(ctx context.Context, appId int32, deliveryId int64) (model.WebhookDelivery, error)
+}
+
+type webhookAPI struct {
+	webhookv1.UnimplementedWebhookServiceServer
+	service WebhookService
+}
+
+func RegisterWebhookAPI(gRPC *grpc.Server, service WebhookService) {
+	webhookv1.RegisterWebhookServiceServer(gRPC, &webhookAPI{service: service})
+}
+
+// CreateWebhook returns the signing secret of the new webhook. It is only
+// returned here.
+func (s *webhookAPI) CreateWebhook(ctx context.Context, req *webhookv1.CreateWebhookRequest) (*webhookv1.CreateWebhookResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetUrl() == "" {
+		return nil, grpcerr.Status(apperr.Required("url"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	subscription, err := s.service.CreateWebhook(ctx, req.GetAppId(), req.GetUrl(), req.GetEventTypes())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &webhookv1.CreateWebhookResponse{
+		Webhook: toWebhook(subscription),
+		Secret:  subscription.Secret,
+	}, nil
+}
+
+func (s *webhookAPI) ListWebhooks(ctx context.Context, req *webhookv1.ListWebhooksRequest) (*webhookv1.ListWebhooksResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	subscriptions, err := s.service.ListWebhooks(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &webhookv1.ListWebhooksResponse{}
+	for _, subscription := range subscriptions {
+		resp.Webhooks = append(resp.Webhooks, toWebhook(subscription))
+	}
+
+	return resp, nil
+}
+
+func (s *webhookAPI) DeleteWebhook(ctx context.Context, req *webhookv1.DeleteWebhookRequest) (*webhookv1.DeleteWebhookResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetWebhookId() <= 0 {
+		return nil, grpcerr.Status(apperr.Required("webhook_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	if err := s.service.DeleteWebhook(ctx, req.GetAppId(), req.GetWebhookId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &webhookv1.DeleteWebhookResponse{Success: true}, nil
+}
+
+// ListWebhookDeliveries lists deliveries newest first, optionally of one
+// webhook or in one status; dead deliveries are the dead-letter queue.
+func (s *webhookAPI) ListWebhookDeliveries(ctx context.Context, req *webhookv1.ListWebhookDeliveriesRequest) (*webhookv1.ListWebhookDeliveriesResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetWebhookId() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("webhook_id", "must not be negative"))
+	}
+
+	if req.GetPageSize() < 0 {
+		return nil, grpcerr.Status(apperr.Invalid("page_size", "must not be negative"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	deliveries, nextPageToken, err := s.service.ListDeliveries(ctx, req.GetAppId(), req.GetWebhookId(), req.GetStatus(), int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	resp := &webhookv1.ListWebhookDeliveriesResponse{
+		NextPageToken: nextPageToken,
+	}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, toDelivery(delivery))
+	}
+
+	return resp, nil
+}
+
+// ReplayWebhook sends a delivered or dead delivery again.
+func (s *webhookAPI) ReplayWebhook(ctx context.Context, req *webhookv1.ReplayWebhookRequest) (*webhookv1.ReplayWebhookResponse, error) {
+	if !govalidator.IsPositive(float64(req.GetAppId())) {
+		return nil, grpcerr.Status(apperr.Required("app_id"))
+	}
+
+	if req.GetDeliveryId() <= 0 {
+		return nil, grpcerr.Status(apperr.Required("delivery_id"))
+	}
+
+	if err := principal.AuthorizeApp(ctx, req.GetAppId()); err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	delivery, err := s.service.ReplayDelivery(ctx, req.GetAppId(), req.GetDeliveryId())
+	if err != nil {
+		return nil, grpcerr.Status(err)
+	}
+
+	return &webhookv1.ReplayWebhookResponse{Delivery: toDelivery(delivery)}, nil
+}
+
+func toWebhook(subscription model.WebhookSubscription) *webhookv1.Webhook {
+	return &webhookv1.Webhook{
+		Id:         subscription.Id,
+		AppId:      subscription.AppId,
+		Url:        subscription.URL,
+		EventTypes: subscription.EventTypes,
+		CreatedAt:  timestamppb.New(subscription.CreatedAt),
+	}
+}
+
+func toDelivery(delivery model.WebhookDelivery) *webhookv1.WebhookDelivery {
+	resp := &webhookv1.WebhookDelivery{
+		Id:        delivery.Id,
+		WebhookId: delivery.SubscriptionId,
+		EventId:   delivery.EventId,
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  int32(delivery.Attempts),
+		CreatedAt: timestamppb.New(delivery.CreatedAt),
+	}
+
+	if delivery.Status == model.DeliveryPending {
+		resp.NextAttemptAt = timestamppb.New(delivery.NextAttemptAt)
+	}
+	if delivery.LastError != nil {
+		resp.LastError = *delivery.LastError
+	}
+	if delivery.LastStatusCode != nil {
+		resp.LastStatusCode = int32(*delivery.LastStatusCode)
+	}
+	if delivery.DeliveredAt != nil {
+		resp.DeliveredAt = timestamppb.New(*delivery.DeliveredAt)
+	}
+
+	return resp
+}
@@ -0,0 +1,56 @@
+package jwkshttp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ei-jobs/auth-service/pkg/jwk"
+)
+
+type KeyService interface {
+	JWKS(ctx context.Context, appId int32) (jwk.Set, error)
+}
+
+type handler struct {
+	log  *slog.Logger
+	keys KeyService
+}
+
+// RegisterHandler serves the JWKS document at the well-known path. The
+// optional app_id query parameter adds that app's keys to the global ones.
+func RegisterHandler(mux *http.ServeMux, log *slog.Logger, keys KeyService) {
+	h := &handler{log: log, keys: keys}
+
+	mux.HandleFunc("GET /.well-known/jwks.json", h.jwks)
+}
+
+func (h *handler) jwks(w http.ResponseWriter, r *http.Request) {
+	const op = "jwkshttp.jwks"
+
+	var appId int64
+	if raw := r.URL.Query().Get("app_id"); raw != "" {
+		var err error
+		appId, err = strconv.ParseInt(raw, 10, 32)
+		if err != nil || appId < 0 {
+			http.Error(w, "invalid app_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	set, err := h.keys.JWKS(r.Context(), int32(appId))
+	if err != nil {
+		h.log.Error("failed to load JWKS", slog.String("op", op), slog.String("error", err.Error()))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		h.log.Error("failed to write JWKS", slog.String("op", op), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,18 @@
+// Package backoff computes exponential retry delays.
+package backoff
+
+import "time"
+
+// Delay is base doubled for every earlier failed attempt, capped at max.
+func Delay(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
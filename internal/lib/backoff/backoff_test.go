@@ -0,0 +1,28 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		base     time.Duration
+		max      time.Duration
+		want     time.Duration
+	}{
+		{attempts: 0, base: time.Second, max: time.Minute, want: time.Second},
+		{attempts: 1, base: time.Second, max: time.Minute, want: 2 * time.Second},
+		{attempts: 5, base: time.Second, max: time.Minute, want: 32 * time.Second},
+		{attempts: 6, base: time.Second, max: time.Minute, want: time.Minute},
+		{attempts: 1000, base: time.Second, max: time.Minute, want: time.Minute},
+		{attempts: 0, base: 2 * time.Minute, max: time.Minute, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Delay(tt.attempts, tt.base, tt.max); got != tt.want {
+			t.Errorf("Delay(%d, %v, %v) = %v, want %v", tt.attempts, tt.base, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package events holds EventPublisher implementations: the log and file
+// publishers for local development, which need no message broker, and
+// Fanout, which combines publishers.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+// Publisher is implemented by every destination of domain events.
+type Publisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// Fanout publishes every event to each of its publishers. An event that
+// fails for one of them is retried for all, so they must all tolerate
+// duplicates.
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, event model.Event) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogPublisher writes events to the log instead of delivering them.
+type LogPublisher struct {
+	log *slog.Logger
+}
+
+func NewLogPublisher(log *slog.Logger) *LogPublisher {
+	return &LogPublisher{log: log}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event model.Event) error {
+	p.log.Info("event",
+		slog.Int64("id", event.Id),
+		slog.String("type", event.Type),
+		slog.Int64("user_id", event.UserId),
+		slog.String("payload", string(event.Payload)),
+	)
+
+	return nil
+}
+
+// FilePublisher appends every event as a JSON line to a file, which tests
+// and local tooling can tail.
+type FilePublisher struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFilePublisher(path string) *FilePublisher {
+	return &FilePublisher{path: path}
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event model.Event) error {
+	const op = "events.FilePublisher.Publish"
+
+	line, err := json.Marshal(event.Envelope())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
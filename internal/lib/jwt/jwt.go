@@ -1,25 +1,180 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func NewToken(user *model.User, app *model.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+var ErrInvalidToken = errors.New("invalid token")
+
+// ScopeAdmin is the scope claim of tokens issued to admins, which may act on
+// any user.
+const ScopeAdmin = "admin"
+
+type Claims struct {
+	Uid           int64
+	Phone         string
+	PhoneVerified bool
+	AppId         int32
+	SessionId     string
+	Jti           string
+	Admin         bool
+	Roles         []string
+	Permissions   []string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+type options struct {
+	compactRBAC bool
+}
+
+type Option func(*options)
+
+// WithCompactRBAC encodes the roles and permissions claims as single
+// space-separated strings, like the OAuth scope claim, instead of arrays.
+func WithCompactRBAC() Option {
+	return func(o *options) {
+		o.compactRBAC = true
+	}
+}
+
+// NewToken signs the claims for user with key. Asymmetric keys put their id
+// into the kid header so verifiers can pick the key from the JWKS.
+func NewToken(user *model.User, app *model.App, key *Key, sessionId string, duration time.Duration, opts ...Option) (string, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	jti, err := opaque.New(16)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.New(key.Method)
+	if key.Id != "" {
+		token.Header["kid"] = key.Id
+	}
+
+	now := time.Now()
 
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.Id
 	claims["phone"] = user.Phone
-	claims["exp"] = time.Now().Add(duration).Unix()
+	claims["phone_verified"] = user.PhoneVerified()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(duration).Unix()
 	claims["app_id"] = app.Id
+	claims["sid"] = sessionId
+	claims["jti"] = jti
+	if user.IsAdmin {
+		claims["scope"] = ScopeAdmin
+	}
+	if len(user.Roles) > 0 {
+		claims["roles"] = listClaim(user.Roles, o.compactRBAC)
+	}
+	if len(user.Permissions) > 0 {
+		claims["permissions"] = listClaim(user.Permissions, o.compactRBAC)
+	}
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	tokenString, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, nil
 }
+
+// ParseToken verifies the signature and expiry of tokenString. The key is
+// resolved through keyFn from the kid header and the app_id claim, and the
+// token must be signed with the algorithm that key belongs to.
+func ParseToken(tokenString string, keyFn func(kid string, appId int32) (*Key, error)) (*Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		appId, ok := claims["app_id"].(float64)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := keyFn(kid, int32(appId))
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrInvalidToken
+		}
+
+		return key.VerifyKey, nil
+	},
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mapClaims := token.Claims.(jwt.MapClaims)
+
+	uid, _ := mapClaims["uid"].(float64)
+	appId, _ := mapClaims["app_id"].(float64)
+	iat, _ := mapClaims["iat"].(float64)
+	exp, _ := mapClaims["exp"].(float64)
+	phone, _ := mapClaims["phone"].(string)
+	phoneVerified, _ := mapClaims["phone_verified"].(bool)
+	sid, _ := mapClaims["sid"].(string)
+	jti, _ := mapClaims["jti"].(string)
+	scope, _ := mapClaims["scope"].(string)
+
+	return &Claims{
+		Uid:           int64(uid),
+		Phone:         phone,
+		PhoneVerified: phoneVerified,
+		AppId:         int32(appId),
+		SessionId:     sid,
+		Jti:           jti,
+		Admin:         scope == ScopeAdmin,
+		Roles:         parseListClaim(mapClaims["roles"]),
+		Permissions:   parseListClaim(mapClaims["permissions"]),
+		IssuedAt:      time.Unix(int64(iat), 0),
+		ExpiresAt:     time.Unix(int64(exp), 0),
+	}, nil
+}
+
+func listClaim(values []string, compact bool) interface{} {
+	if compact {
+		return strings.Join(values, " ")
+	}
+	return values
+}
+
+// parseListClaim accepts both the array and the compact space-separated form.
+func parseListClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BreachedList is an in-memory set of breached passwords. Only SHA-1 digests
+// are kept, which also lets it load the offline Have I Been Pwned dumps.
+type BreachedList struct {
+	digests map[[sha1.Size]byte]struct{}
+}
+
+// LoadBreachedList reads one entry per line. An entry is either a plain
+// password or, as in the Have I Been Pwned dumps, a hex SHA-1 digest
+// optionally followed by ":count". Blank lines and lines starting with "#"
+// are skipped.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	const op = "password.LoadBreachedList"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	list := &BreachedList{digests: make(map[[sha1.Size]byte]struct{})}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if digest, ok := parseDigest(line); ok {
+			list.digests[digest] = struct{}{}
+			continue
+		}
+
+		list.digests[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	_, ok := l.digests[sha1.Sum([]byte(password))]
+	return ok
+}
+
+func (l *BreachedList) Len() int {
+	return len(l.digests)
+}
+
+func parseDigest(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != hex.EncodedLen(sha1.Size) {
+		return digest, false
+	}
+
+	if _, err := hex.Decode(digest[:], []byte(hash)); err != nil {
+		return digest, false
+	}
+
+	return digest, true
+}
@@ -0,0 +1,207 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+)
+
+// pepperPrefix marks hashes of peppered passwords, so hashes stored before a
+// pepper was configured keep verifying.
+const pepperPrefix = "$pepper"
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hashing algorithm")
+	ErrInvalidHash          = errors.New("invalid password hash")
+	ErrPepperRequired       = errors.New("hash was peppered but no pepper is configured")
+)
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes of any supported algorithm. Bcrypt hashes are stored in their
+// native "$2a$" form and argon2id hashes in the PHC string format, so every
+// hash names the algorithm and parameters it was made with.
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+	pepper     []byte
+}
+
+// NewHasher configures a hasher. A non-empty pepper is mixed into every new
+// hash with HMAC-SHA256 and must then stay configured to verify them.
+func NewHasher(algorithm string, bcryptCost int, argon2 Argon2Params, pepper string) (*Hasher, error) {
+	switch algorithm {
+	case AlgBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgArgon2id:
+		if argon2.Memory == 0 || argon2.Iterations == 0 || argon2.Parallelism == 0 || argon2.SaltLength == 0 || argon2.KeyLength == 0 {
+			return nil, errors.New("argon2id parameters must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return &Hasher{
+		algorithm:  algorithm,
+		bcryptCost: bcryptCost,
+		argon2:     argon2,
+		pepper:     []byte(pepper),
+	}, nil
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	input := h.season(password)
+
+	var hash string
+	switch h.algorithm {
+	case AlgBcrypt:
+		b, err := bcrypt.GenerateFromPassword(input, h.bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		hash = string(b)
+	case AlgArgon2id:
+		salt := make([]byte, h.argon2.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		hash = encodeArgon2(h.argon2, salt, argon2.IDKey(input, salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength))
+	}
+
+	if len(h.pepper) > 0 {
+		hash = pepperPrefix + hash
+	}
+
+	return []byte(hash), nil
+}
+
+// Verify reports whether password matches hash, and whether a matching hash
+// should be replaced because it was made with another algorithm, other
+// parameters or without the current pepper.
+func (h *Hasher) Verify(hash []byte, password string) (ok bool, needsRehash bool, err error) {
+	encoded := string(hash)
+
+	input := []byte(password)
+	peppered := strings.HasPrefix(encoded, pepperPrefix)
+	if peppered {
+		if len(h.pepper) == 0 {
+			return false, false, ErrPepperRequired
+		}
+		encoded = strings.TrimPrefix(encoded, pepperPrefix)
+		input = h.season(password)
+	}
+
+	needsRehash = peppered != (len(h.pepper) > 0)
+
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey(input, salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		needsRehash = needsRehash || h.algorithm != AlgArgon2id ||
+			params.Memory != h.argon2.Memory ||
+			params.Iterations != h.argon2.Iterations ||
+			params.Parallelism != h.argon2.Parallelism ||
+			uint32(len(salt)) != h.argon2.SaltLength ||
+			uint32(len(key)) != h.argon2.KeyLength
+	case strings.HasPrefix(encoded, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), input); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+		}
+		needsRehash = needsRehash || h.algorithm != AlgBcrypt || cost != h.bcryptCost
+	default:
+		return false, false, ErrInvalidHash
+	}
+
+	return true, needsRehash, nil
+}
+
+// season mixes the pepper into the password. The HMAC is base64 encoded so
+// that it stays within the 72 bytes bcrypt reads.
+func (h *Hasher) season(password string) []byte {
+	if len(h.pepper) == 0 {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(password))
+
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func encodeArgon2(p Argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
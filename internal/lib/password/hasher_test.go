@@ -0,0 +1,210 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2 = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func newTestHasher(t *testing.T, algorithm string, bcryptCost int, argon2 Argon2Params, pepper string) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(algorithm, bcryptCost, argon2, pepper)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+
+	return h
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  string
+		bcryptCost int
+		argon2     Argon2Params
+		wantErr    bool
+	}{
+		{name: "bcrypt", algorithm: AlgBcrypt, bcryptCost: bcrypt.MinCost},
+		{name: "bcrypt cost too low", algorithm: AlgBcrypt, bcryptCost: bcrypt.MinCost - 1, wantErr: true},
+		{name: "bcrypt cost too high", algorithm: AlgBcrypt, bcryptCost: bcrypt.MaxCost + 1, wantErr: true},
+		{name: "argon2id", algorithm: AlgArgon2id, argon2: testArgon2},
+		{name: "argon2id zero parameters", algorithm: AlgArgon2id, wantErr: true},
+		{name: "unknown algorithm", algorithm: "md5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHasher(tt.algorithm, tt.bcryptCost, tt.argon2, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewHasher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  string
+		pepper     string
+		wantPrefix string
+	}{
+		{name: "bcrypt", algorithm: AlgBcrypt, wantPrefix: "$2"},
+		{name: "argon2id", algorithm: AlgArgon2id, wantPrefix: "$argon2id$"},
+		{name: "bcrypt with pepper", algorithm: AlgBcrypt, pepper: "pepper", wantPrefix: pepperPrefix + "$2"},
+		{name: "argon2id with pepper", algorithm: AlgArgon2id, pepper: "pepper", wantPrefix: pepperPrefix + "$argon2id$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHasher(t, tt.algorithm, bcrypt.MinCost, testArgon2, tt.pepper)
+
+			hash, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !strings.HasPrefix(string(hash), tt.wantPrefix) {
+				t.Errorf("hash = %q, want prefix %q", hash, tt.wantPrefix)
+			}
+
+			ok, needsRehash, err := h.Verify(hash, "correct horse")
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+			}
+
+			ok, needsRehash, err = h.Verify(hash, "wrong horse")
+			if err != nil || ok || needsRehash {
+				t.Errorf("Verify(wrong) = %v, %v, %v, want false, false, nil", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	otherArgon2 := testArgon2
+	otherArgon2.Iterations = 2
+
+	tests := []struct {
+		name            string
+		hashedBy        *Hasher
+		verifiedBy      *Hasher
+		wantNeedsRehash bool
+		wantErr         error
+	}{
+		{
+			name:       "same bcrypt settings",
+			hashedBy:   newTestHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy: newTestHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2, ""),
+		},
+		{
+			name:            "bcrypt cost changed",
+			hashedBy:        newTestHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy:      newTestHasher(t, AlgBcrypt, bcrypt.MinCost+1, testArgon2, ""),
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "bcrypt to argon2id",
+			hashedBy:        newTestHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy:      newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, ""),
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "argon2id to bcrypt",
+			hashedBy:        newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy:      newTestHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2, ""),
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "argon2id parameters changed",
+			hashedBy:        newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy:      newTestHasher(t, AlgArgon2id, bcrypt.MinCost, otherArgon2, ""),
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "pepper added",
+			hashedBy:        newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, ""),
+			verifiedBy:      newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, "pepper"),
+			wantNeedsRehash: true,
+		},
+		{
+			name:       "pepper removed",
+			hashedBy:   newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, "pepper"),
+			verifiedBy: newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, ""),
+			wantErr:    ErrPepperRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hashedBy.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+
+			ok, needsRehash, err := tt.verifiedBy.Verify(hash, "correct horse")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil || !ok {
+				t.Fatalf("Verify() = %v, %v, want true, nil", ok, err)
+			}
+			if needsRehash != tt.wantNeedsRehash {
+				t.Errorf("needsRehash = %v, want %v", needsRehash, tt.wantNeedsRehash)
+			}
+
+			if needsRehash {
+				rehashed, err := tt.verifiedBy.Hash("correct horse")
+				if err != nil {
+					t.Fatalf("Hash() error = %v", err)
+				}
+				ok, needsRehash, err := tt.verifiedBy.Verify(rehashed, "correct horse")
+				if err != nil || !ok || needsRehash {
+					t.Errorf("Verify(rehashed) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	h := newTestHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2, "")
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "unknown scheme", hash: "$1$abc"},
+		{name: "truncated argon2id", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+		{name: "wrong argon2 version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5"},
+		{name: "bad argon2 salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5"},
+		{name: "malformed bcrypt", hash: "$2a$04$short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, _, err := h.Verify([]byte(tt.hash), "password")
+			if !errors.Is(err, ErrInvalidHash) {
+				t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidHash)
+			}
+			if ok {
+				t.Error("ok = true for invalid hash")
+			}
+		})
+	}
+}
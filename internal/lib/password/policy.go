@@ -0,0 +1,177 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrPolicyViolation = errors.New("password does not satisfy policy")
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would only
+// give a false sense of strength.
+const maxLength = 72
+
+// Rules reported in Violation.Rule.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+	RuleReused       = "reused"
+)
+
+// minPersonalLength keeps short name parts such as "Li" from rejecting
+// unrelated passwords.
+const minPersonalLength = 3
+
+// Violation is a failed rule and a message that can be shown to the user.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// PolicyError lists every rule a password failed. It matches
+// ErrPolicyViolation with errors.Is.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return ErrPolicyViolation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// BreachedChecker reports whether a password is known from a breach.
+type BreachedChecker interface {
+	Contains(password string) bool
+}
+
+// Policy is the set of rules a password must satisfy. A zero MaxLength
+// allows the 72 bytes bcrypt can use.
+type Policy struct {
+	MinLength          int
+	MaxLength          int
+	RequireUppercase   bool
+	RequireLowercase   bool
+	RequireDigit       bool
+	RequireSymbol      bool
+	ForbidPersonalInfo bool
+	Breached           BreachedChecker
+}
+
+// Validate checks password against every rule and reports all failures at
+// once. personal holds the phone, name and other values the password must
+// not contain when ForbidPersonalInfo is set.
+func (p Policy) Validate(password string, personal ...string) error {
+	var violations []Violation
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if limit := p.maxLength(); len(password) > limit {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d bytes long", limit),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, Violation{Rule: RuleUppercase, Message: "must contain an uppercase letter"})
+	}
+
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, Violation{Rule: RuleLowercase, Message: "must contain a lowercase letter"})
+	}
+
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{Rule: RuleDigit, Message: "must contain a digit"})
+	}
+
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, Violation{Rule: RuleSymbol, Message: "must contain a symbol"})
+	}
+
+	if p.ForbidPersonalInfo && containsPersonal(password, personal) {
+		violations = append(violations, Violation{Rule: RulePersonalInfo, Message: "must not contain your phone number or name"})
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, Violation{Rule: RuleBreached, Message: "appears in a list of breached passwords"})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (p Policy) maxLength() int {
+	if p.MaxLength > 0 && p.MaxLength < maxLength {
+		return p.MaxLength
+	}
+	return maxLength
+}
+
+// containsPersonal compares case-insensitively. Phones are also matched by
+// their digits alone and names word by word, so "+7 701 123" and "Ivan
+// Petrov" catch "7701123" and "petrov1990".
+func containsPersonal(password string, personal []string) bool {
+	lower := strings.ToLower(password)
+
+	for _, value := range personal {
+		candidates := strings.Fields(strings.ToLower(value))
+		if digits := onlyDigits(value); digits != "" {
+			candidates = append(candidates, digits)
+		}
+
+		for _, c := range candidates {
+			if len([]rune(c)) >= minPersonalLength && strings.Contains(lower, c) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type breachedSet map[string]bool
+
+func (s breachedSet) Contains(password string) bool { return s[password] }
+
+func TestPolicyValidate(t *testing.T) {
+	strict := Policy{
+		MinLength:          8,
+		RequireUppercase:   true,
+		RequireLowercase:   true,
+		RequireDigit:       true,
+		RequireSymbol:      true,
+		ForbidPersonalInfo: true,
+		Breached:           breachedSet{"Passw0rd!": true},
+	}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		password  string
+		personal  []string
+		wantRules []string
+	}{
+		{
+			name:     "zero policy allows anything short of the byte limit",
+			password: "a",
+		},
+		{
+			name:     "strict policy satisfied",
+			policy:   strict,
+			password: "Tr0ub4dor&3",
+			personal: []string{"+7 701 123 4567", "Ivan Petrov"},
+		},
+		{
+			name:      "too short",
+			policy:    Policy{MinLength: 8},
+			password:  "short",
+			wantRules: []string{RuleMinLength},
+		},
+		{
+			name:     "min length counts runes",
+			policy:   Policy{MinLength: 4},
+			password: "пароль",
+		},
+		{
+			name:      "over configured max length",
+			policy:    Policy{MaxLength: 10},
+			password:  strings.Repeat("a", 11),
+			wantRules: []string{RuleMaxLength},
+		},
+		{
+			name:      "max length capped at bcrypt limit",
+			policy:    Policy{MaxLength: 100},
+			password:  strings.Repeat("a", 73),
+			wantRules: []string{RuleMaxLength},
+		},
+		{
+			name:      "max length counts bytes",
+			password:  strings.Repeat("я", 37),
+			wantRules: []string{RuleMaxLength},
+		},
+		{
+			name:      "every character class missing",
+			policy:    strict,
+			password:  "        ",
+			wantRules: []string{RuleUppercase, RuleLowercase, RuleDigit},
+		},
+		{
+			name:      "symbol missing",
+			policy:    strict,
+			password:  "Tr0ub4dor3",
+			wantRules: []string{RuleSymbol},
+		},
+		{
+			name:      "contains phone digits",
+			policy:    strict,
+			password:  "X!77011234567x",
+			personal:  []string{"+7 701 123 4567"},
+			wantRules: []string{RulePersonalInfo},
+		},
+		{
+			name:      "contains name part case-insensitively",
+			policy:    strict,
+			password:  "PETROV1990!x",
+			personal:  []string{"Ivan Petrov"},
+			wantRules: []string{RulePersonalInfo},
+		},
+		{
+			name:     "short name parts are ignored",
+			policy:   strict,
+			password: "Lithium1990!",
+			personal: []string{"Li"},
+		},
+		{
+			name:     "personal info allowed when not forbidden",
+			policy:   Policy{MinLength: 8},
+			password: "petrov1990",
+			personal: []string{"Ivan Petrov"},
+		},
+		{
+			name:      "breached",
+			policy:    strict,
+			password:  "Passw0rd!",
+			wantRules: []string{RuleBreached},
+		},
+		{
+			name:      "all violations reported together",
+			policy:    strict,
+			password:  "ivan",
+			personal:  []string{"Ivan"},
+			wantRules: []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol, RulePersonalInfo},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.personal...)
+			if len(tt.wantRules) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrPolicyViolation) {
+				t.Fatalf("Validate() error = %v, want %v", err, ErrPolicyViolation)
+			}
+
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %T, want *PolicyError", err)
+			}
+
+			var rules []string
+			for _, v := range policyErr.Violations {
+				rules = append(rules, v.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := strings.Join([]string{
+		"# comment",
+		"",
+		"letmein",
+		// SHA-1 of "password", as in the Have I Been Pwned dumps.
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493",
+		"qwerty\r",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedList() error = %v", err)
+	}
+
+	if list.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", list.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "letmein", want: true},
+		{password: "password", want: true},
+		{password: "qwerty", want: true},
+		{password: "# comment"},
+		{password: "correct horse"},
+	}
+
+	for _, tt := range tests {
+		if got := list.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
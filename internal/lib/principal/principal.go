@@ -0,0 +1,72 @@
+package principal
+
+import (
+	"context"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+)
+
+// Principal is the authenticated caller of an RPC: either a user holding an
+// access token or another service presenting its configured credential.
+type Principal struct {
+	UserId      int64
+	AppId       int32
+	SessionId   string
+	Admin       bool
+	Service     string
+	Roles       []string
+	Permissions []string
+}
+
+func (p *Principal) IsService() bool {
+	return p.Service != ""
+}
+
+// CanActOn reports whether the caller may read or modify the given user of
+// appId. Users may only act on themselves, admins on users of their own app
+// and services on anyone.
+func (p *Principal) CanActOn(userId int64, appId int32) bool {
+	return p.IsService() || (p.Admin && p.AppId == appId) || (p.UserId == userId && p.AppId == appId)
+}
+
+// CanManageApp reports whether the caller may manage the roles and
+// permissions of the given app: services for any app, admins for their own.
+func (p *Principal) CanManageApp(appId int32) bool {
+	return p.IsService() || (p.Admin && p.AppId == appId)
+}
+
+// AuthorizeApp admits services and admins of the given app. It returns
+// apperr.ErrUnauthenticated without a principal in ctx and
+// apperr.ErrPermissionDenied for anyone else.
+func AuthorizeApp(ctx context.Context, appId int32) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return apperr.ErrUnauthenticated
+	}
+
+	if !p.CanManageApp(appId) {
+		return apperr.ErrPermissionDenied.WithMessage("not allowed to manage this app")
+	}
+
+	return nil
+}
+
+func (p *Principal) HasPermission(name string) bool {
+	for _, permission := range p.Permissions {
+		if permission == name {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
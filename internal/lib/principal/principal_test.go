@@ -0,0 +1,69 @@
+package principal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+)
+
+func TestAuthorizeApp(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		appId     int32
+		wantErr   error
+	}{
+		{name: "no principal", appId: 1, wantErr: apperr.ErrUnauthenticated},
+		{name: "service", principal: &Principal{Service: "billing"}, appId: 2},
+		{name: "admin of the app", principal: &Principal{UserId: 1, AppId: 1, Admin: true}, appId: 1},
+		{name: "admin of another app", principal: &Principal{UserId: 1, AppId: 2, Admin: true}, appId: 1, wantErr: apperr.ErrPermissionDenied},
+		{name: "user of the app", principal: &Principal{UserId: 1, AppId: 1}, appId: 1, wantErr: apperr.ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = WithPrincipal(ctx, tt.principal)
+			}
+
+			err := AuthorizeApp(ctx, tt.appId)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("AuthorizeApp() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthorizeApp() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanActOn(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		userId    int64
+		appId     int32
+		want      bool
+	}{
+		{name: "service", principal: Principal{Service: "billing"}, userId: 7, appId: 2, want: true},
+		{name: "self", principal: Principal{UserId: 7, AppId: 1}, userId: 7, appId: 1, want: true},
+		{name: "same id in another app", principal: Principal{UserId: 7, AppId: 1}, userId: 7, appId: 2},
+		{name: "other user", principal: Principal{UserId: 7, AppId: 1}, userId: 8, appId: 1},
+		{name: "admin of the app", principal: Principal{UserId: 1, AppId: 1, Admin: true}, userId: 8, appId: 1, want: true},
+		{name: "admin of another app", principal: Principal{UserId: 1, AppId: 1, Admin: true}, userId: 8, appId: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.CanActOn(tt.userId, tt.appId); got != tt.want {
+				t.Errorf("CanActOn(%d, %d) = %v, want %v", tt.userId, tt.appId, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type counterKey struct {
+	key   string
+	start int64
+}
+
+// MemoryStore keeps counters in process memory, so every replica limits
+// on its own.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[counterKey]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[counterKey]int64),
+	}
+}
+
+func (s *MemoryStore) Hit(_ context.Context, key string, start time.Time, previous time.Time) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := counterKey{key: key, start: start.UnixNano()}
+	s.counters[current]++
+
+	return s.counters[current], s.counters[counterKey{key: key, start: previous.UnixNano()}], nil
+}
+
+func (s *MemoryStore) Cleanup(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.counters {
+		if k.start < before.UnixNano() {
+			delete(s.counters, k)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package ratelimit implements a sliding-window rate limiter over fixed
+// window counters, which can be kept in memory or in a shared store.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps hit counters per key and window start.
+type Store interface {
+	// Hit counts a hit in the window starting at start and returns the
+	// counts of that window and of the one starting at previous.
+	Hit(ctx context.Context, key string, start time.Time, previous time.Time) (current int64, prev int64, err error)
+	// Cleanup deletes the counters of windows that started before t.
+	Cleanup(ctx context.Context, before time.Time) error
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow counts a hit for key and reports whether it stays within limit hits
+// per window. The rate is estimated by weighting the previous window's
+// count by how much of it still overlaps the sliding window. When the hit
+// is refused, retryAfter is the time until the current window ends.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error) {
+	now := l.now().UTC()
+	start := now.Truncate(window)
+
+	current, previous, err := l.store.Hit(ctx, key, start, start.Add(-window))
+	if err != nil {
+		return false, 0, err
+	}
+
+	elapsed := now.Sub(start)
+	weight := float64(window-elapsed) / float64(window)
+	estimate := float64(previous)*weight + float64(current)
+
+	if estimate > float64(limit) {
+		return false, start.Add(window).Sub(now), nil
+	}
+
+	return true, 0, nil
+}
+
+// Cleanup drops counters that can no longer affect windows of up to
+// maxWindow.
+func (l *Limiter) Cleanup(ctx context.Context, maxWindow time.Duration) error {
+	return l.store.Cleanup(ctx, l.now().UTC().Add(-2*maxWindow))
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(now time.Time) *Limiter {
+	l := NewLimiter(NewMemoryStore())
+	l.now = func() time.Time { return now }
+	return l
+}
+
+func TestAllow(t *testing.T) {
+	window := time.Minute
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		previousHits   int
+		currentHits    int
+		elapsed        time.Duration
+		limit          int
+		wantAllowed    bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:        "first hit",
+			limit:       3,
+			wantAllowed: true,
+		},
+		{
+			name:        "last hit within limit",
+			currentHits: 2,
+			limit:       3,
+			wantAllowed: true,
+		},
+		{
+			name:           "over limit in current window",
+			currentHits:    3,
+			elapsed:        15 * time.Second,
+			limit:          3,
+			wantRetryAfter: 45 * time.Second,
+		},
+		{
+			name:           "previous window fully weighted at window start",
+			previousHits:   3,
+			limit:          3,
+			wantRetryAfter: time.Minute,
+		},
+		{
+			name:         "previous window half weighted",
+			previousHits: 4,
+			elapsed:      30 * time.Second,
+			limit:        3,
+			wantAllowed:  true,
+		},
+		{
+			name:           "previous window half weighted over limit",
+			previousHits:   4,
+			currentHits:    2,
+			elapsed:        30 * time.Second,
+			limit:          3,
+			wantRetryAfter: 30 * time.Second,
+		},
+		{
+			name:           "previous window almost expired",
+			previousHits:   150,
+			elapsed:        59 * time.Second,
+			limit:          3,
+			wantRetryAfter: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			l := newTestLimiter(start.Add(-window))
+			for i := 0; i < tt.previousHits; i++ {
+				if _, _, err := l.Allow(ctx, "key", 1000, window); err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+			}
+
+			l.now = func() time.Time { return start }
+			for i := 0; i < tt.currentHits; i++ {
+				if _, _, err := l.Allow(ctx, "key", 1000, window); err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+			}
+
+			l.now = func() time.Time { return start.Add(tt.elapsed) }
+			allowed, retryAfter, err := l.Allow(ctx, "key", tt.limit, window)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if retryAfter != tt.wantRetryAfter {
+				t.Errorf("retryAfter = %v, want %v", retryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestAllowKeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLimiter(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	if allowed, _, _ := l.Allow(ctx, "a", 1, time.Minute); !allowed {
+		t.Fatal("first hit of a refused")
+	}
+	if allowed, _, _ := l.Allow(ctx, "a", 1, time.Minute); allowed {
+		t.Fatal("second hit of a allowed")
+	}
+	if allowed, _, _ := l.Allow(ctx, "b", 1, time.Minute); !allowed {
+		t.Fatal("first hit of b refused")
+	}
+}
+
+type failingStore struct{ err error }
+
+func (s failingStore) Hit(context.Context, string, time.Time, time.Time) (int64, int64, error) {
+	return 0, 0, s.err
+}
+
+func (s failingStore) Cleanup(context.Context, time.Time) error { return s.err }
+
+func TestAllowStoreError(t *testing.T) {
+	storeErr := errors.New("store down")
+
+	allowed, _, err := NewLimiter(failingStore{err: storeErr}).Allow(context.Background(), "key", 1, time.Minute)
+	if !errors.Is(err, storeErr) {
+		t.Fatalf("Allow() error = %v, want %v", err, storeErr)
+	}
+	if allowed {
+		t.Error("allowed = true on store error")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+
+	l := NewLimiter(store)
+	l.now = func() time.Time { return start }
+	if _, _, err := l.Allow(ctx, "key", 1, time.Minute); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+
+	l.now = func() time.Time { return start.Add(time.Minute) }
+	if err := l.Cleanup(ctx, time.Minute); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if len(store.counters) != 1 {
+		t.Fatalf("counters after early cleanup = %d, want 1", len(store.counters))
+	}
+
+	l.now = func() time.Time { return start.Add(3 * time.Minute) }
+	if err := l.Cleanup(ctx, time.Minute); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if len(store.counters) != 0 {
+		t.Fatalf("counters after cleanup = %d, want 0", len(store.counters))
+	}
+}
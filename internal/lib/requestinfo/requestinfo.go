@@ -0,0 +1,23 @@
+// Package requestinfo carries details about the client of a request, such
+// as its address, from the transport layer down to the services.
+package requestinfo
+
+import "context"
+
+type Info struct {
+	IP        string
+	UserAgent string
+}
+
+type contextKey struct{}
+
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the request info, or the zero Info outside of a
+// request.
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(contextKey{}).(Info)
+	return info
+}
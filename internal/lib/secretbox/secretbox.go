@@ -0,0 +1,89 @@
+// Package secretbox encrypts secrets at rest with AES-256-GCM under a
+// master key from the config.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values, so secrets stored in plaintext before
+// encryption was introduced can be told apart and migrated.
+const prefix = "enc:v1:"
+
+var (
+	ErrInvalidKey   = errors.New("master key must be 32 bytes")
+	ErrInvalidValue = errors.New("invalid sealed value")
+)
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New builds a box from a base64-encoded 32-byte master key.
+func New(encodedKey string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value. Values without the prefix are legacy
+// plaintext and are returned unchanged.
+func (b *Box) Open(value string) (string, error) {
+	if !b.IsSealed(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", ErrInvalidValue
+	}
+
+	plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	return string(plaintext), nil
+}
+
+func (b *Box) IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
@@ -0,0 +1,198 @@
+// Package webhook sends signed webhook requests. Every request carries the
+// Unix time it was signed at and an HMAC-SHA256 of that time and the body
+// under the subscription's secret:
+//
+//	X-Webhook-Timestamp: 1700000000
+//	X-Webhook-Signature: sha256=hex(HMAC-SHA256(secret, "1700000000." + body))
+//
+// Receivers recompute the signature, compare it in constant time and
+// reject old timestamps to stop replays.
+//
+// Webhook URLs are chosen by the apps, so requests must not reach the
+// service's own network. DialControl refuses such addresses once the host
+// has been resolved.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	signaturePrefix = "sha256="
+	// maxResponseBody is how much of a response is read, so that the
+	// connection can be reused.
+	maxResponseBody = 64 << 10
+)
+
+// ErrForbiddenAddress is returned for connections to addresses that
+// ForbiddenIP refuses.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// StatusError is returned for responses outside the 2xx range.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook endpoint responded with status %d", e.StatusCode)
+}
+
+// Sign returns the signature header value of body signed at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body signed at
+// timestamp with secret.
+func Verify(secret string, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// forbiddenNetworks are the ranges webhooks may not be sent to: every
+// special-purpose range of the IANA registries that is not globally
+// reachable, plus multicast, reserved space and the IPv6 prefixes that
+// translate to IPv4 addresses, which could smuggle in any of the others.
+var forbiddenNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "this" network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier-grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local, including cloud metadata
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.88.99.0/24",  // 6to4 relay anycast
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, including broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // NAT64
+	"64:ff9b:1::/48",  // local-use NAT64
+	"100::/64",        // discard-only
+	"2001::/23",       // IETF protocol assignments, including Teredo
+	"2001:db8::/32",   // documentation
+	"2002::/16",       // 6to4
+	"3fff::/20",       // documentation
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"fec0::/10",       // site-local
+	"ff00::/8",        // multicast
+)
+
+// ForbiddenIP reports whether ip lies in a range webhooks may not be sent
+// to, such as loopback, private, link-local, shared, reserved or multicast
+// space. IPv4-mapped IPv6 addresses are checked as IPv4.
+func ForbiddenIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// DialControl is a net.Dialer Control function that refuses connections to
+// forbidden addresses. It sees the address the host resolved to at dial
+// time, so it also stops hosts that resolve to internal addresses, or that
+// only started to after the webhook was created.
+func DialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ForbiddenIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// Sender posts webhook requests through its HTTP client, which sets the
+// timeouts and can point at a test server.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(client *http.Client) *Sender {
+	return &Sender{client: client}
+}
+
+// Send posts body to url, signed with secret. It returns the response
+// status, or 0 if there was no response, and a *StatusError for statuses
+// outside the 2xx range.
+func (s *Sender) Send(ctx context.Context, url string, secret string, deliveryId int64, eventType string, body []byte) (int, error) {
+	const op = "webhook.Send"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "auth-service-webhooks")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(deliveryId, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s: %w", op, &StatusError{StatusCode: resp.StatusCode})
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"user.registered"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		want      bool
+	}{
+		{name: "valid", secret: "secret", timestamp: 1700000000, body: body, want: true},
+		{name: "other secret", secret: "other", timestamp: 1700000000, body: body},
+		{name: "other timestamp", secret: "secret", timestamp: 1700000001, body: body},
+		{name: "other body", secret: "secret", timestamp: 1700000000, body: []byte(`{}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, signature, tt.timestamp, tt.body); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendHeaders(t *testing.T) {
+	body := []byte(`{"type":"user.registered"}`)
+
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	statusCode, err := NewSender(srv.Client()).Send(context.Background(), srv.URL, "secret", 42, "user.registered", body)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if statusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", statusCode, http.StatusNoContent)
+	}
+
+	if got.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.Method)
+	}
+	if ct := got.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if event := got.Header.Get(EventHeader); event != "user.registered" {
+		t.Errorf("%s = %q", EventHeader, event)
+	}
+	if delivery := got.Header.Get(DeliveryHeader); delivery != "42" {
+		t.Errorf("%s = %q", DeliveryHeader, delivery)
+	}
+
+	timestamp, err := strconv.ParseInt(got.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("%s = %q: %v", TimestampHeader, got.Header.Get(TimestampHeader), err)
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d < 0 || d > time.Minute {
+		t.Errorf("%s is %v old", TimestampHeader, d)
+	}
+
+	if !Verify("secret", got.Header.Get(SignatureHeader), timestamp, gotBody) {
+		t.Errorf("%s = %q does not verify", SignatureHeader, got.Header.Get(SignatureHeader))
+	}
+}
+
+func TestSendStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusAccepted},
+		{status: http.StatusFound, wantErr: true},
+		{status: http.StatusBadRequest, wantErr: true},
+		{status: http.StatusGone, wantErr: true},
+		{status: http.StatusInternalServerError, wantErr: true},
+		{status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			client := srv.Client()
+			client.CheckRedirect = func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+
+			statusCode, err := NewSender(client).Send(context.Background(), srv.URL, "secret", 1, "user.registered", []byte(`{}`))
+			if statusCode != tt.status {
+				t.Errorf("status = %d, want %d", statusCode, tt.status)
+			}
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				return
+			}
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("Send() error = %v, want *StatusError", err)
+			}
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("StatusError.StatusCode = %d, want %d", statusErr.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	statusCode, err := NewSender(srv.Client()).Send(ctx, srv.URL, "secret", 1, "user.registered", []byte(`{}`))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if statusCode != 0 {
+		t.Errorf("status = %d, want 0", statusCode)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		t.Error("timeout reported as a StatusError")
+	}
+}
+
+func TestForbiddenIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "127.0.0.1", want: true},
+		{ip: "127.1.2.3", want: true},
+		{ip: "::1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.16.0.1", want: true},
+		{ip: "192.168.1.1", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "fe80::1", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "::", want: true},
+		{ip: "::ffff:127.0.0.1", want: true},
+		{ip: "::ffff:10.0.0.1", want: true},
+		{ip: "0.1.2.3", want: true},
+		{ip: "100.64.0.1", want: true},
+		{ip: "100.127.255.254", want: true},
+		{ip: "192.0.0.8", want: true},
+		{ip: "192.0.2.1", want: true},
+		{ip: "192.88.99.1", want: true},
+		{ip: "198.18.0.1", want: true},
+		{ip: "198.19.255.254", want: true},
+		{ip: "198.51.100.1", want: true},
+		{ip: "203.0.113.1", want: true},
+		{ip: "224.0.0.1", want: true},
+		{ip: "239.255.255.250", want: true},
+		{ip: "240.0.0.1", want: true},
+		{ip: "255.255.255.255", want: true},
+		{ip: "64:ff9b::a00:1", want: true},
+		{ip: "64:ff9b:1::1", want: true},
+		{ip: "100::1", want: true},
+		{ip: "2001::1", want: true},
+		{ip: "2001:db8::1", want: true},
+		{ip: "2002:a00:1::1", want: true},
+		{ip: "3fff::1", want: true},
+		{ip: "fec0::1", want: true},
+		{ip: "ff02::1", want: true},
+		{ip: "ff0e::1", want: true},
+		{ip: "8.8.8.8"},
+		{ip: "172.32.0.1"},
+		{ip: "100.63.255.255"},
+		{ip: "100.128.0.1"},
+		{ip: "198.17.255.255"},
+		{ip: "198.20.0.1"},
+		{ip: "223.255.255.254"},
+		{ip: "2001:4860:4860::8888"},
+		{ip: "2606:4700:4700::1111"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := ForbiddenIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("ForbiddenIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "8.8.8.8:443"},
+		{address: "[2001:4860:4860::8888]:443"},
+		{address: "127.0.0.1:8080", wantErr: true},
+		{address: "[::1]:8080", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "10.0.0.1:443", wantErr: true},
+		{address: "example.com:443", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := DialControl("tcp", tt.address, nil)
+			if tt.wantErr && !errors.Is(err, ErrForbiddenAddress) {
+				t.Fatalf("DialControl() error = %v, want %v", err, ErrForbiddenAddress)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("DialControl() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSendRefusesInternalAddress(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Control: DialControl}).DialContext,
+		},
+	}
+
+	statusCode, err := NewSender(client).Send(context.Background(), srv.URL, "secret", 1, "user.registered", []byte(`{}`))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Send() error = %v, want %v", err, ErrForbiddenAddress)
+	}
+	if statusCode != 0 {
+		t.Errorf("status = %d, want 0", statusCode)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+}
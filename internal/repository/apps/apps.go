@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAppNotFound   = apperr.New(apperr.NotFound, "APP_NOT_FOUND", "app not found")
+	ErrAppExists     = apperr.New(apperr.AlreadyExists, "APP_EXISTS", "app already exists")
+	ErrSecretChanged = apperr.New(apperr.FailedPrecondition, "APP_SECRET_CHANGED", "app secret changed concurrently")
+)
+
+const uniqueViolation = "23505"
+
+const appColumns = `id, name, secret, previous_secret, previous_secret_expires_at,
+	secret_rotated_at, allow_unverified_login, disabled_at, created_at,
+	access_ttl_seconds, refresh_ttl_seconds, password_min_length, login_methods,
+	password_max_length, password_require_uppercase, password_require_lowercase, password_require_digit,
+	password_require_symbol, password_forbid_personal_info, password_check_breached`
+
+type AppRepository struct {
+	db *sql.DB
+}
+
+func NewAppRepository(db *sql.DB) *AppRepository {
+	return &AppRepository{db: db}
+}
+
+func (r *AppRepository) CreateApp(ctx context.Context, app *model.App) error {
+	const op = "repository.CreateApp"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO apps (
+			name,
+			secret,
+			allow_unverified_login
+		) VALUES ($1, $2, $3)
+		RETURNING id, created_at;
+	`, app.Name, app.Secret, app.AllowUnverifiedLogin).Scan(&app.Id, &app.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *AppRepository) GetApp(ctx context.Context, appId int32) (model.App, error) {
+	const op = "repository.GetApp"
+
+	app, err := scanApp(r.db.QueryRowContext(ctx, `
+		SELECT `+appColumns+`
+		FROM apps
+		WHERE id = $1
+	`, appId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return app, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+func (r *AppRepository) ListApps(ctx context.Context, includeDisabled bool) ([]model.App, error) {
+	const op = "repository.ListApps"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+appColumns+`
+		FROM apps
+		WHERE $1 OR disabled_at IS NULL
+		ORDER BY id
+	`, includeDisabled)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []model.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+func (r *AppRepository) UpdateApp(ctx context.Context, app *model.App) error {
+	const op = "repository.UpdateApp"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE apps
+		SET name = $2, allow_unverified_login = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, app.Id, app.Name, app.AllowUnverifiedLogin)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	return nil
+}
+
+// SetDisabled disables the app at disabledAt, or enables it when nil.
+func (r *AppRepository) SetDisabled(ctx context.Context, appId int32, disabledAt *time.Time) error {
+	const op = "repository.SetDisabled"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE apps
+		SET disabled_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, appId, disabledAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	return nil
+}
+
+func (r *AppRepository) DeleteApp(ctx context.Context, appId int32) (bool, error) {
+	const op = "repository.DeleteApp"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM apps
+		WHERE id = $1
+	`, appId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *AppRepository) CountUsers(ctx context.Context, appId int32) (int64, error) {
+	const op = "repository.CountUsers"
+	var count int64
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM users
+		WHERE app_id = $1
+	`, appId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// RotateSecret installs secret and keeps the current one as the previous
+// secret until previousExpiresAt. It fails with ErrSecretChanged if the
+// stored secret no longer equals currentSecret, so concurrent rotations do
+// not lose a secret that is still in use.
+func (r *AppRepository) RotateSecret(ctx context.Context, appId int32, currentSecret string, secret string, previousSecret string, previousExpiresAt time.Time) (time.Time, error) {
+	const op = "repository.RotateSecret"
+	var rotatedAt time.Time
+
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE apps
+		SET secret = $3,
+			previous_secret = $4,
+			previous_secret_expires_at = $5,
+			secret_rotated_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND secret = $2
+		RETURNING secret_rotated_at
+	`, appId, currentSecret, secret, previousSecret, previousExpiresAt).Scan(&rotatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rotatedAt, fmt.Errorf("%s: %w", op, ErrSecretChanged)
+		}
+		return rotatedAt, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rotatedAt, nil
+}
+
+// ReplaceSecrets rewrites the stored secrets in place, for encrypting
+// plaintext ones. It reports false if the secret changed in the meantime.
+func (r *AppRepository) ReplaceSecrets(ctx context.Context, appId int32, currentSecret string, secret string, previousSecret *string) (bool, error) {
+	const op = "repository.ReplaceSecrets"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE apps
+		SET secret = $3, previous_secret = $4
+		WHERE id = $1 AND secret = $2
+	`, appId, currentSecret, secret, previousSecret)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UpdateSettings replaces the app's settings. Zero values and nil rules are
+// stored as NULL so that the app follows the service defaults.
+func (r *AppRepository) UpdateSettings(ctx context.Context, appId int32, settings model.AppSettings, allowUnverifiedLogin bool) error {
+	const op = "repository.UpdateSettings"
+
+	var loginMethods interface{}
+	if len(settings.LoginMethods) > 0 {
+		loginMethods = pq.Array(settings.LoginMethods)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE apps
+		SET access_ttl_seconds = $2,
+			refresh_ttl_seconds = $3,
+			password_min_length = $4,
+			login_methods = $5,
+			allow_unverified_login = $6,
+			password_max_length = $7,
+			password_require_uppercase = $8,
+			password_require_lowercase = $9,
+			password_require_digit = $10,
+			password_require_symbol = $11,
+			password_forbid_personal_info = $12,
+			password_check_breached = $13,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, appId,
+		nullInt(int64(settings.AccessTTL/time.Second)),
+		nullInt(int64(settings.RefreshTTL/time.Second)),
+		nullInt(int64(settings.PasswordMinLength)),
+		loginMethods,
+		allowUnverifiedLogin,
+		nullInt(int64(settings.PasswordMaxLength)),
+		settings.PasswordRequireUppercase,
+		settings.PasswordRequireLowercase,
+		settings.PasswordRequireDigit,
+		settings.PasswordRequireSymbol,
+		settings.PasswordForbidPersonalInfo,
+		settings.PasswordCheckBreached,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApp(row scanner) (model.App, error) {
+	var app model.App
+	var accessTTL, refreshTTL, passwordMinLength, passwordMaxLength sql.NullInt64
+
+	err := row.Scan(
+		&app.Id,
+		&app.Name,
+		&app.Secret,
+		&app.PreviousSecret,
+		&app.PreviousSecretExpiresAt,
+		&app.SecretRotatedAt,
+		&app.AllowUnverifiedLogin,
+		&app.DisabledAt,
+		&app.CreatedAt,
+		&accessTTL,
+		&refreshTTL,
+		&passwordMinLength,
+		pq.Array(&app.Settings.LoginMethods),
+		&passwordMaxLength,
+		&app.Settings.PasswordRequireUppercase,
+		&app.Settings.PasswordRequireLowercase,
+		&app.Settings.PasswordRequireDigit,
+		&app.Settings.PasswordRequireSymbol,
+		&app.Settings.PasswordForbidPersonalInfo,
+		&app.Settings.PasswordCheckBreached,
+	)
+	if err != nil {
+		return app, err
+	}
+
+	app.Settings.AccessTTL = time.Duration(accessTTL.Int64) * time.Second
+	app.Settings.RefreshTTL = time.Duration(refreshTTL.Int64) * time.Second
+	app.Settings.PasswordMinLength = int(passwordMinLength.Int64)
+	app.Settings.PasswordMaxLength = int(passwordMaxLength.Int64)
+
+	return app, nil
+}
+
+func nullInt(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
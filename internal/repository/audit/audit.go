@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+// auditChainLock is the advisory lock that serialises appends, so that
+// every event is chained to the one inserted right before it.
+const auditChainLock = 7_281_001
+
+const auditColumns = `id, type, app_id, actor_user_id, actor_service, target_user_id, ip, user_agent, metadata, created_at, prev_hash, hash`
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AppendEvent chains the event to the last stored one, sets its CreatedAt,
+// PrevHash and Hash and inserts it.
+func (r *AuditRepository) AppendEvent(ctx context.Context, event *model.AuditEvent) (err error) {
+	const op = "repository.AppendEvent"
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if event.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var prevHash string
+	err = tx.QueryRowContext(ctx, `
+		SELECT hash
+		FROM audit_events
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Postgres keeps microseconds, and the hash has to match what is read
+	// back.
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.PrevHash = prevHash
+	event.Hash = event.ComputeHash()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_events (type, app_id, actor_user_id, actor_service, target_user_id, ip, user_agent, metadata, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`,
+		event.Type,
+		event.AppId,
+		nullableId(event.ActorUserId),
+		nullableString(event.ActorService),
+		nullableId(event.TargetUserId),
+		event.IP,
+		event.UserAgent,
+		metadata,
+		event.CreatedAt,
+		event.PrevHash,
+		event.Hash,
+	).Scan(&event.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListEvents returns up to limit events matching filter with ids below
+// beforeId, newest first. A beforeId of 0 starts from the newest event.
+func (r *AuditRepository) ListEvents(ctx context.Context, filter model.AuditFilter, beforeId int64, limit int) ([]model.AuditEvent, error) {
+	const op = "repository.ListEvents"
+
+	args := []any{filter.AppId}
+	conditions := []string{"app_id = $1"}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	if filter.ActorUserId != 0 {
+		args = append(args, filter.ActorUserId)
+		conditions = append(conditions, fmt.Sprintf("actor_user_id = $%d", len(args)))
+	}
+
+	if filter.TargetUserId != 0 {
+		args = append(args, filter.TargetUserId)
+		conditions = append(conditions, fmt.Sprintf("target_user_id = $%d", len(args)))
+	}
+
+	if filter.CreatedAfter != nil {
+		args = append(args, filter.CreatedAfter.UTC())
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	if filter.CreatedBefore != nil {
+		args = append(args, filter.CreatedBefore.UTC())
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if beforeId > 0 {
+		args = append(args, beforeId)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_events
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// ListChain returns up to limit events with ids above afterId in the order
+// they were chained.
+func (r *AuditRepository) ListChain(ctx context.Context, afterId int64, limit int) ([]model.AuditEvent, error) {
+	const op = "repository.ListChain"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+	for rows.Next() {
+		var (
+			event        model.AuditEvent
+			actorUserId  sql.NullInt64
+			actorService sql.NullString
+			targetUserId sql.NullInt64
+			metadata     []byte
+		)
+
+		err := rows.Scan(
+			&event.Id,
+			&event.Type,
+			&event.AppId,
+			&actorUserId,
+			&actorService,
+			&targetUserId,
+			&event.IP,
+			&event.UserAgent,
+			&metadata,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+
+		event.ActorUserId = actorUserId.Int64
+		event.ActorService = actorService.String
+		event.TargetUserId = targetUserId.Int64
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func nullableId(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
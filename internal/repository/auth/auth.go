@@ -3,11 +3,23 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
 	"github.com/ei-jobs/auth-service/internal/domain/model"
+	outbox "github.com/ei-jobs/auth-service/internal/repository/outbox"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrUserNotFound = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+	ErrUserExists   = apperr.New(apperr.AlreadyExists, "USER_EXISTS", "user already exists")
+)
+
+const uniqueViolation = "23505"
+
 type AuthRepository struct {
 	db *sql.DB
 }
@@ -16,11 +28,18 @@ func NewAuthRepository(db *sql.DB) *AuthRepository {
 	return &AuthRepository{db: db}
 }
 
+// StoreUser inserts the user and queues a UserRegistered event.
 func (r *AuthRepository) StoreUser(ctx context.Context, phone string, name string, appId int32, password []byte) (int64, error) {
 	const op = "repository.StoreUser"
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
 	var user_id int64
-	err := r.db.QueryRow(`
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO users (
 			name,
 			phone,
@@ -30,6 +49,18 @@ func (r *AuthRepository) StoreUser(ctx context.Context, phone string, name strin
 		RETURNING id;
 	`, name, phone, password, appId).Scan(&user_id)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return -1, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user := model.User{Id: user_id, Name: name, Phone: phone, AppId: appId}
+	if err := outbox.InsertEvent(ctx, tx, model.NewUserEvent(model.EventUserRegistered, user, time.Now())); err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -41,96 +72,155 @@ func (r *AuthRepository) GetUserByPhone(ctx context.Context, phone string, app_i
 	var user model.User
 
 	err := r.db.QueryRow(`
-		SELECT id, name, password, phone, app_id
+		SELECT id, name, password, phone, app_id, phone_verified_at, is_admin, suspended_at, suspended_until
 		FROM users
-		WHERE phone = $1 AND app_id = $2
-	`, phone, app_id).Scan(&user.Id, &user.Name, &user.PassHash, &user.Phone, &user.AppId)
+		WHERE phone = $1 AND app_id = $2 AND deleted_at IS NULL
+	`, phone, app_id).Scan(&user.Id, &user.Name, &user.PassHash, &user.Phone, &user.AppId, &user.PhoneVerifiedAt, &user.IsAdmin, &user.SuspendedAt, &user.SuspendedUntil)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
 		return user, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, nil
 }
 
-func (r *AuthRepository) UpdateUser(ctx context.Context, user *model.User) (*model.User, error) {
-	const op = "repository.UpdateUser"
-
-	_, err := r.db.ExecContext(ctx, `
-		UPDATE users
-		SET name = $1, avatar_url = $2, description = $3
-		WHERE id = $4
-	`, user.Name, user.AvatarUrl, user.Description, user.Id)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	return user, nil
-}
-
 func (r *AuthRepository) GetUserById(ctx context.Context, user_id int64) (*model.User, error) {
 	const op = "repository.GetUser"
 	var user model.User
 
 	err := r.db.QueryRowContext(ctx, `
-		SELECT id, name, password, phone, app_id, avatar_url, description, balance
+		SELECT id, name, password, phone, app_id, avatar_url, description, balance, phone_verified_at, is_admin, suspended_at, suspended_until
 		FROM users
-		WHERE id = $1
-	`, user_id).Scan(&user.Id, &user.Name, &user.PassHash, &user.Phone, &user.AppId, &user.AvatarUrl, &user.Description, &user.Balance)
+		WHERE id = $1 AND deleted_at IS NULL
+	`, user_id).Scan(&user.Id, &user.Name, &user.PassHash, &user.Phone, &user.AppId, &user.AvatarUrl, &user.Description, &user.Balance, &user.PhoneVerifiedAt, &user.IsAdmin, &user.SuspendedAt, &user.SuspendedUntil)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return &user, nil
 }
 
-func (r *AuthRepository) DeleteUser(ctx context.Context, user_id int64) (bool, error) {
-	const op = "repository.DeleteUser"
-
-	result, err := r.db.ExecContext(ctx, `
-		DELETE FROM users
-		WHERE id = $1
-	`, user_id)
+// UpdatePassword stores the password hash and queues a PasswordChanged
+// event. With verifyPhone an unverified phone is marked verified as well,
+// and a PhoneVerified event is queued with it.
+func (r *AuthRepository) UpdatePassword(ctx context.Context, phone string, app_id int32, password []byte, verifyPhone bool) (model.User, error) {
+	const op = "repository.UpdatePassword"
+	var (
+		user     model.User
+		verified bool
+	)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
+		return user, fmt.Errorf("%s: %w", op, err)
 	}
-
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users u
+		SET password = $1, updated_at = CURRENT_TIMESTAMP,
+			phone_verified_at = CASE WHEN $4 THEN COALESCE(u.phone_verified_at, CURRENT_TIMESTAMP) ELSE u.phone_verified_at END
+		FROM (
+			SELECT id, phone_verified_at
+			FROM users
+			WHERE phone = $2 AND app_id = $3 AND deleted_at IS NULL
+			FOR UPDATE
+		) old
+		WHERE u.id = old.id
+		RETURNING u.id, u.name, u.phone, u.app_id, u.avatar_url, u.description, u.phone_verified_at, u.is_admin,
+			u.suspended_at, u.suspended_until, old.phone_verified_at IS NULL AND u.phone_verified_at IS NOT NULL
+	`, password, phone, app_id, verifyPhone).Scan(&user.Id, &user.Name, &user.Phone, &user.AppId, &user.AvatarUrl, &user.Description,
+		&user.PhoneVerifiedAt, &user.IsAdmin, &user.SuspendedAt, &user.SuspendedUntil, &verified)
 	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return rowsAffected > 0, nil
+	now := time.Now()
+
+	if verified {
+		if err := outbox.InsertEvent(ctx, tx, model.NewUserEvent(model.EventPhoneVerified, user, now)); err != nil {
+			return user, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := outbox.InsertEvent(ctx, tx, model.NewUserEvent(model.EventPasswordChanged, user, now)); err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
 }
 
-func (r *AuthRepository) UpdatePassword(ctx context.Context, phone string, app_id int32, password []byte) (model.User, error) {
-	const op = "repository.GetUserByPhone"
+// MarkPhoneVerified sets the phone of the user as verified and queues a
+// PhoneVerified event, unless it was verified already.
+func (r *AuthRepository) MarkPhoneVerified(ctx context.Context, user_id int64) error {
+	const op = "repository.MarkPhoneVerified"
 	var user model.User
 
-	_, err := r.db.Exec(`
-			UPDATE users
-			SET password = $1
-			WHERE phone = $2 AND app_id = $3
-		`, password, phone, app_id)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return user, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	return user, nil
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users
+		SET phone_verified_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND phone_verified_at IS NULL AND deleted_at IS NULL
+		RETURNING id, name, phone, app_id, avatar_url, description, phone_verified_at
+	`, user_id).Scan(&user.Id, &user.Name, &user.Phone, &user.AppId, &user.AvatarUrl, &user.Description, &user.PhoneVerifiedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.InsertEvent(ctx, tx, model.NewUserEvent(model.EventPhoneVerified, user, time.Now())); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
 }
 
-func (r *AuthRepository) GetAppById(ctx context.Context, app_id int32) (model.App, error) {
-    const op = "repository.GetAppById"
-    var app model.App
+// ReplacePasswordHash swaps the stored hash for newHash only if it still
+// equals oldHash, and reports whether it did.
+func (r *AuthRepository) ReplacePasswordHash(ctx context.Context, user_id int64, oldHash []byte, newHash []byte) (bool, error) {
+	const op = "repository.ReplacePasswordHash"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET password = $1
+		WHERE id = $2 AND password = $3 AND deleted_at IS NULL
+	`, newHash, user_id, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 
-    err := r.db.QueryRow(`
-        SELECT id, name, secret 
-        FROM apps
-        WHERE id = $1
-    `, app_id).Scan(&app.Id, &app.Name, &app.Secret)
-    if err != nil {
-        return app, fmt.Errorf("%s: %w", op, err)
-    }
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 
-    return app, nil
-} 
+	return rowsAffected > 0, nil
+}
 
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+var ErrSessionNotFound = apperr.New(apperr.NotFound, "SESSION_NOT_FOUND", "session not found")
+
+func (r *AuthRepository) CreateSession(ctx context.Context, session *model.Session) error {
+	const op = "repository.CreateSession"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO sessions (
+			id,
+			user_id,
+			app_id,
+			expires_at
+		) VALUES ($1, $2, $3, $4)
+		RETURNING created_at, last_used_at;
+	`, session.Id, session.UserId, session.AppId, session.ExpiresAt).Scan(&session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetSession(ctx context.Context, id string) (model.Session, error) {
+	const op = "repository.GetSession"
+	var session model.Session
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, app_id, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`, id).Scan(&session.Id, &session.UserId, &session.AppId, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return session, fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		return session, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (r *AuthRepository) TouchSession(ctx context.Context, id string, expiresAt time.Time) error {
+	const op = "repository.TouchSession"
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET last_used_at = $1, expires_at = $2
+		WHERE id = $3
+	`, time.Now(), expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) ListActiveSessions(ctx context.Context, userId int64, appId int32) ([]model.Session, error) {
+	const op = "repository.ListActiveSessions"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, app_id, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND app_id = $2 AND revoked_at IS NULL AND expires_at > $3
+		ORDER BY last_used_at DESC
+	`, userId, appId, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(&session.Id, &session.UserId, &session.AppId, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func (r *AuthRepository) RevokeSession(ctx context.Context, id string) (bool, error) {
+	const op = "repository.RevokeSession"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+	`, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RevokeUserSessions revokes every active session of the user in the app
+// except exceptId, which may be empty, and returns how many were revoked.
+func (r *AuthRepository) RevokeUserSessions(ctx context.Context, userId int64, appId int32, exceptId string) (int64, error) {
+	const op = "repository.RevokeUserSessions"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND app_id = $3 AND id <> $4 AND revoked_at IS NULL
+	`, time.Now(), userId, appId, exceptId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
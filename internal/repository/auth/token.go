@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+var ErrRefreshTokenNotFound = apperr.New(apperr.NotFound, "REFRESH_TOKEN_NOT_FOUND", "refresh token not found")
+
+func (r *AuthRepository) StoreRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	const op = "repository.StoreRefreshToken"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (
+			token_hash,
+			session_id,
+			user_id,
+			app_id,
+			expires_at
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`, token.TokenHash, token.SessionId, token.UserId, token.AppId, token.ExpiresAt).Scan(&token.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error) {
+	const op = "repository.GetRefreshToken"
+	var token model.RefreshToken
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, token_hash, session_id, user_id, app_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, tokenHash).Scan(&token.Id, &token.TokenHash, &token.SessionId, &token.UserId, &token.AppId, &token.ExpiresAt, &token.RevokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return token, fmt.Errorf("%s: %w", op, ErrRefreshTokenNotFound)
+		}
+		return token, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// RevokeRefreshToken marks the token as used. It reports false when the token
+// had already been revoked, which means a concurrent request rotated it first.
+func (r *AuthRepository) RevokeRefreshToken(ctx context.Context, id int64) (bool, error) {
+	const op = "repository.RevokeRefreshToken"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+	`, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+var ErrKeyNotFound = apperr.New(apperr.NotFound, "SIGNING_KEY_NOT_FOUND", "signing key not found")
+
+type KeyRepository struct {
+	db *sql.DB
+}
+
+func NewKeyRepository(db *sql.DB) *KeyRepository {
+	return &KeyRepository{db: db}
+}
+
+const keyColumns = `id, kid, app_id, algorithm, private_key, public_key, created_at, retired_at, expires_at`
+
+func (r *KeyRepository) StoreKey(ctx context.Context, key *model.SigningKey) error {
+	const op = "repository.StoreKey"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO signing_keys (
+			kid,
+			app_id,
+			algorithm,
+			private_key,
+			public_key
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at;
+	`, key.Kid, key.AppId, key.Algorithm, key.PrivateKey, key.PublicKey).Scan(&key.Id, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReplacePrivateKey rewrites the stored private key in place, for
+// encrypting plaintext ones. It reports false if the key changed in the
+// meantime.
+func (r *KeyRepository) ReplacePrivateKey(ctx context.Context, id int64, currentKey string, privateKey string) (bool, error) {
+	const op = "repository.ReplacePrivateKey"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET private_key = $3
+		WHERE id = $1 AND private_key = $2
+	`, id, currentKey, privateKey)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *KeyRepository) GetKeyByKid(ctx context.Context, kid string) (model.SigningKey, error) {
+	const op = "repository.GetKeyByKid"
+
+	key, err := scanKey(r.db.QueryRowContext(ctx, `
+		SELECT `+keyColumns+`
+		FROM signing_keys
+		WHERE kid = $1
+	`, kid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+		return key, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// GetActiveKey returns the newest unretired key of the given scope, where a
+// nil appId selects the global scope.
+func (r *KeyRepository) GetActiveKey(ctx context.Context, appId *int32, algorithm string) (model.SigningKey, error) {
+	const op = "repository.GetActiveKey"
+
+	key, err := scanKey(r.db.QueryRowContext(ctx, `
+		SELECT `+keyColumns+`
+		FROM signing_keys
+		WHERE app_id IS NOT DISTINCT FROM $1 AND algorithm = $2 AND retired_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, appId, algorithm))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+		return key, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (r *KeyRepository) ListActiveKeys(ctx context.Context) ([]model.SigningKey, error) {
+	const op = "repository.ListActiveKeys"
+
+	keys, err := r.listKeys(ctx, `
+		SELECT `+keyColumns+`
+		FROM signing_keys
+		WHERE retired_at IS NULL
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// ListPublishedKeys returns the global keys and the keys of appId that are
+// still valid for verification.
+func (r *KeyRepository) ListPublishedKeys(ctx context.Context, appId int32) ([]model.SigningKey, error) {
+	const op = "repository.ListPublishedKeys"
+
+	keys, err := r.listKeys(ctx, `
+		SELECT `+keyColumns+`
+		FROM signing_keys
+		WHERE (app_id IS NULL OR app_id = $1) AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY created_at DESC
+	`, appId, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (r *KeyRepository) RetireKey(ctx context.Context, id int64, retiredAt time.Time, expiresAt time.Time) error {
+	const op = "repository.RetireKey"
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET retired_at = $1, expires_at = $2
+		WHERE id = $3 AND retired_at IS NULL
+	`, retiredAt, expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *KeyRepository) listKeys(ctx context.Context, query string, args ...interface{}) ([]model.SigningKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.SigningKey
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanKey(row scanner) (model.SigningKey, error) {
+	var key model.SigningKey
+	var appId sql.NullInt32
+
+	err := row.Scan(&key.Id, &key.Kid, &appId, &key.Algorithm, &key.PrivateKey, &key.PublicKey, &key.CreatedAt, &key.RetiredAt, &key.ExpiresAt)
+	if err != nil {
+		return key, err
+	}
+
+	if appId.Valid {
+		key.AppId = &appId.Int32
+	}
+
+	return key, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+type LockoutRepository struct {
+	db *sql.DB
+}
+
+func NewLockoutRepository(db *sql.DB) *LockoutRepository {
+	return &LockoutRepository{db: db}
+}
+
+// GetThrottle returns the state of the key, or a zero throttle if it has no
+// recorded failures.
+func (r *LockoutRepository) GetThrottle(ctx context.Context, scope string, key string) (model.LoginThrottle, error) {
+	const op = "repository.GetThrottle"
+	throttle := model.LoginThrottle{Scope: scope, Key: key}
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return throttle, nil
+		}
+		return throttle, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return throttle, nil
+}
+
+// RecordFailure counts a failed attempt at now. Failures older than
+// windowStart no longer count and the counter restarts from one.
+func (r *LockoutRepository) RecordFailure(ctx context.Context, scope string, key string, now time.Time, windowStart time.Time) (model.LoginThrottle, error) {
+	const op = "repository.RecordFailure"
+	throttle := model.LoginThrottle{Scope: scope, Key: key}
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET failures = CASE
+				WHEN login_throttles.last_failure_at < $4 THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = $3
+		RETURNING failures, last_failure_at, locked_until
+	`, scope, key, now, windowStart).Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err != nil {
+		return throttle, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return throttle, nil
+}
+
+// Lock locks the key until the given time and clears its failures, so the
+// key starts afresh once the lock expires.
+func (r *LockoutRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	const op = "repository.Lock"
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE login_throttles
+		SET locked_until = $3, failures = 0
+		WHERE scope = $1 AND key = $2
+	`, scope, key, until)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *LockoutRepository) Reset(ctx context.Context, scope string, key string) (bool, error) {
+	const op = "repository.Reset"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM login_throttles
+		WHERE scope = $1 AND key = $2
+	`, scope, key)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// DeleteStale removes keys whose failures predate windowStart and that are
+// not locked at now.
+func (r *LockoutRepository) DeleteStale(ctx context.Context, windowStart time.Time, now time.Time) (int64, error) {
+	const op = "repository.DeleteStale"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)
+	`, windowStart, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+var ErrCodeNotFound = apperr.New(apperr.NotFound, "OTP_CODE_NOT_FOUND", "otp code not found")
+
+type OTPRepository struct {
+	db *sql.DB
+}
+
+func NewOTPRepository(db *sql.DB) *OTPRepository {
+	return &OTPRepository{db: db}
+}
+
+func (r *OTPRepository) StoreCode(ctx context.Context, code *model.OTPCode) error {
+	const op = "repository.StoreCode"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO otp_codes (
+			phone,
+			app_id,
+			purpose,
+			code_hash,
+			expires_at
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at;
+	`, code.Phone, code.AppId, code.Purpose, code.CodeHash, code.ExpiresAt).Scan(&code.Id, &code.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetLatestCode returns the most recently issued code; sending a new code
+// therefore invalidates the earlier ones.
+func (r *OTPRepository) GetLatestCode(ctx context.Context, phone string, appId int32, purpose string) (model.OTPCode, error) {
+	const op = "repository.GetLatestCode"
+	var code model.OTPCode
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, phone, app_id, purpose, code_hash, attempts, expires_at, consumed_at, created_at
+		FROM otp_codes
+		WHERE phone = $1 AND app_id = $2 AND purpose = $3
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, phone, appId, purpose).Scan(&code.Id, &code.Phone, &code.AppId, &code.Purpose, &code.CodeHash, &code.Attempts, &code.ExpiresAt, &code.ConsumedAt, &code.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return code, fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+		}
+		return code, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// IncrementAttempts records a verification attempt. It reports false once
+// maxAttempts have already been used up.
+func (r *OTPRepository) IncrementAttempts(ctx context.Context, id int64, maxAttempts int) (bool, error) {
+	const op = "repository.IncrementAttempts"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE otp_codes
+		SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2
+	`, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ConsumeCode marks the code as used. It reports false when a concurrent
+// request consumed it first.
+func (r *OTPRepository) ConsumeCode(ctx context.Context, id int64) (bool, error) {
+	const op = "repository.ConsumeCode"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE otp_codes
+		SET consumed_at = $1
+		WHERE id = $2 AND consumed_at IS NULL
+	`, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+// maxErrorLength fits the last_error columns of the outbox and of webhook
+// deliveries.
+const maxErrorLength = 1000
+
+// TruncateError cuts lastError to fit a last_error column, without leaving
+// a partial UTF-8 sequence at the end.
+func TruncateError(lastError string) string {
+	if len(lastError) > maxErrorLength {
+		return strings.ToValidUTF8(lastError[:maxErrorLength], "")
+	}
+	return lastError
+}
+
+// InsertEvent adds event to the outbox within tx, which must be the transaction
+// making the change the event describes, so that both are committed or
+// neither is.
+func InsertEvent(ctx context.Context, tx *sql.Tx, event model.Event) error {
+	const op = "repository.InsertEvent"
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox_events (type, app_id, user_id, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`, event.Type, event.AppId, event.UserId, []byte(event.Payload), event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// ClaimEvents leases up to limit unpublished events that are due, oldest
+// first, until now plus lease. Leased events are skipped by other relays,
+// so several replicas can relay at once, and an event whose relay died is
+// picked up again once its lease has run out.
+func (r *OutboxRepository) ClaimEvents(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]model.Event, error) {
+	const op = "repository.ClaimEvents"
+
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox_events
+		SET locked_until = $1
+		WHERE id IN (
+			SELECT id
+			FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= $2 AND (locked_until IS NULL OR locked_until <= $2)
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, app_id, user_id, payload, created_at, attempts
+	`, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		var event model.Event
+		err := rows.Scan(&event.Id, &event.Type, &event.AppId, &event.UserId, &event.Payload, &event.OccurredAt, &event.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// UPDATE ... RETURNING does not keep the order of the subquery.
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64, now time.Time) error {
+	const op = "repository.MarkPublished"
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET published_at = $1, locked_until = NULL
+		WHERE id = $2
+	`, now, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkFailed releases the event for another attempt at nextAttemptAt.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	const op = "repository.MarkFailed"
+
+	lastError = TruncateError(lastError)
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2, locked_until = NULL
+		WHERE id = $3
+	`, nextAttemptAt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeletePublished removes events published before before.
+func (r *OutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	const op = "repository.DeletePublished"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM outbox_events
+		WHERE published_at IS NOT NULL AND published_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RateLimitRepository is a ratelimit.Store shared by all replicas.
+type RateLimitRepository struct {
+	db *sql.DB
+}
+
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+func (r *RateLimitRepository) Hit(ctx context.Context, key string, start time.Time, previous time.Time) (int64, int64, error) {
+	const op = "repository.Hit"
+	var current, prev int64
+
+	err := r.db.QueryRowContext(ctx, `
+		WITH hit AS (
+			INSERT INTO rate_limit_counters (key, window_start, count)
+			VALUES ($1, $2, 1)
+			ON CONFLICT (key, window_start) DO UPDATE
+			SET count = rate_limit_counters.count + 1
+			RETURNING count
+		)
+		SELECT hit.count, COALESCE((
+			SELECT count
+			FROM rate_limit_counters
+			WHERE key = $1 AND window_start = $3
+		), 0)
+		FROM hit
+	`, key, start, previous).Scan(&current, &prev)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return current, prev, nil
+}
+
+func (r *RateLimitRepository) Cleanup(ctx context.Context, before time.Time) error {
+	const op = "repository.Cleanup"
+
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM rate_limit_counters
+		WHERE window_start < $1
+	`, before)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRoleExists         = apperr.New(apperr.AlreadyExists, "ROLE_EXISTS", "role already exists")
+	ErrRoleNotFound       = apperr.New(apperr.NotFound, "ROLE_NOT_FOUND", "role not found")
+	ErrPermissionExists   = apperr.New(apperr.AlreadyExists, "PERMISSION_EXISTS", "permission already exists")
+	ErrPermissionNotFound = apperr.New(apperr.NotFound, "PERMISSION_NOT_FOUND", "permission not found")
+	ErrUserNotFound       = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+)
+
+const uniqueViolation = "23505"
+
+type RBACRepository struct {
+	db *sql.DB
+}
+
+func NewRBACRepository(db *sql.DB) *RBACRepository {
+	return &RBACRepository{db: db}
+}
+
+func (r *RBACRepository) CreateRole(ctx context.Context, role *model.Role) error {
+	const op = "repository.CreateRole"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO roles (
+			app_id,
+			name,
+			description
+		) VALUES ($1, $2, $3)
+		RETURNING id;
+	`, role.AppId, role.Name, role.Description).Scan(&role.Id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrRoleExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) GetRole(ctx context.Context, roleId int64) (model.Role, error) {
+	const op = "repository.GetRole"
+	var role model.Role
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT r.id, r.app_id, r.name, r.description,
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.id = $1
+		GROUP BY r.id
+	`, roleId).Scan(&role.Id, &role.AppId, &role.Name, &role.Description, pq.Array(&role.Permissions))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return role, fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+		return role, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+func (r *RBACRepository) ListRoles(ctx context.Context, appId int32) ([]model.Role, error) {
+	const op = "repository.ListRoles"
+
+	roles, err := r.listRoles(ctx, `
+		SELECT r.id, r.app_id, r.name, r.description,
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.app_id = $1
+		GROUP BY r.id
+		ORDER BY r.name
+	`, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+func (r *RBACRepository) ListUserRoles(ctx context.Context, userId int64, appId int32) ([]model.Role, error) {
+	const op = "repository.ListUserRoles"
+
+	roles, err := r.listRoles(ctx, `
+		SELECT r.id, r.app_id, r.name, r.description,
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1 AND r.app_id = $2
+		GROUP BY r.id
+		ORDER BY r.name
+	`, userId, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+func (r *RBACRepository) DeleteRole(ctx context.Context, roleId int64) (bool, error) {
+	const op = "repository.DeleteRole"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM roles
+		WHERE id = $1
+	`, roleId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *RBACRepository) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	const op = "repository.CreatePermission"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO permissions (
+			app_id,
+			name,
+			description
+		) VALUES ($1, $2, $3)
+		RETURNING id;
+	`, permission.AppId, permission.Name, permission.Description).Scan(&permission.Id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrPermissionExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) GetPermission(ctx context.Context, permissionId int64) (model.Permission, error) {
+	const op = "repository.GetPermission"
+	var permission model.Permission
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, app_id, name, description
+		FROM permissions
+		WHERE id = $1
+	`, permissionId).Scan(&permission.Id, &permission.AppId, &permission.Name, &permission.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return permission, fmt.Errorf("%s: %w", op, ErrPermissionNotFound)
+		}
+		return permission, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permission, nil
+}
+
+func (r *RBACRepository) ListPermissions(ctx context.Context, appId int32) ([]model.Permission, error) {
+	const op = "repository.ListPermissions"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, app_id, name, description
+		FROM permissions
+		WHERE app_id = $1
+		ORDER BY name
+	`, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var permissions []model.Permission
+	for rows.Next() {
+		var permission model.Permission
+		if err := rows.Scan(&permission.Id, &permission.AppId, &permission.Name, &permission.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+func (r *RBACRepository) DeletePermission(ctx context.Context, permissionId int64) (bool, error) {
+	const op = "repository.DeletePermission"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM permissions
+		WHERE id = $1
+	`, permissionId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *RBACRepository) GrantPermission(ctx context.Context, roleId int64, permissionId int64) error {
+	const op = "repository.GrantPermission"
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, roleId, permissionId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) RevokePermission(ctx context.Context, roleId int64, permissionId int64) (bool, error) {
+	const op = "repository.RevokePermission"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM role_permissions
+		WHERE role_id = $1 AND permission_id = $2
+	`, roleId, permissionId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *RBACRepository) AssignRole(ctx context.Context, userId int64, roleId int64) error {
+	const op = "repository.AssignRole"
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, userId, roleId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) RevokeRole(ctx context.Context, userId int64, roleId int64) (bool, error) {
+	const op = "repository.RevokeRole"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = $2
+	`, userId, roleId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// GetUserAppId returns the app the user belongs to, so that roles of one app
+// cannot be assigned to users of another.
+func (r *RBACRepository) GetUserAppId(ctx context.Context, userId int64) (int32, error) {
+	const op = "repository.GetUserAppId"
+	var appId int32
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT app_id
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`, userId).Scan(&appId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return appId, nil
+}
+
+// GetUserAccess returns the names of the user's roles in the app and the
+// union of their permissions.
+func (r *RBACRepository) GetUserAccess(ctx context.Context, userId int64, appId int32) ([]string, []string, error) {
+	const op = "repository.GetUserAccess"
+	var roles, permissions []string
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(ARRAY_AGG(DISTINCT r.name) FILTER (WHERE r.id IS NOT NULL), '{}'),
+			COALESCE(ARRAY_AGG(DISTINCT p.name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id AND r.app_id = $2
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+	`, userId, appId).Scan(pq.Array(&roles), pq.Array(&permissions))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, permissions, nil
+}
+
+func (r *RBACRepository) listRoles(ctx context.Context, query string, args ...interface{}) ([]model.Role, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []model.Role
+	for rows.Next() {
+		var role model.Role
+		if err := rows.Scan(&role.Id, &role.AppId, &role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
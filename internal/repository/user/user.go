@@ -1,11 +1,432 @@
 package repository
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	outbox "github.com/ei-jobs/auth-service/internal/repository/outbox"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUserNotFound = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+	// ErrUserExists means the phone of a deleted user has been registered
+	// again since, so the user cannot be restored.
+	ErrUserExists = apperr.New(apperr.AlreadyExists, "USER_EXISTS", "user already exists")
+)
+
+const uniqueViolation = "23505"
+
+const userColumns = `id, name, phone, app_id, avatar_url, description, balance, phone_verified_at, is_admin, created_at,
+	suspended_at, suspended_until, suspension_reason, suspended_by`
+
+// UserRepository stores users. Every change to a user queues a domain event
+// in the outbox within the same transaction.
 type UserRepository struct {
-    db *sql.DB
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) GetUser(ctx context.Context, userId int64) (model.User, error) {
+	const op = "repository.GetUser"
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`, userId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// GetUsers returns the users with the given ids in a single query. Unknown
+// and deleted ids are skipped.
+func (r *UserRepository) GetUsers(ctx context.Context, userIds []int64) ([]model.User, error) {
+	const op = "repository.GetUsers"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = ANY($1) AND deleted_at IS NULL
+	`, pq.Array(userIds))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// UpdateProfile sets the fields users may change themselves and returns the
+// updated user.
+func (r *UserRepository) UpdateProfile(ctx context.Context, userId int64, name string, avatarUrl *string, description *string) (model.User, error) {
+	const op = "repository.UpdateProfile"
+
+	user, err := r.changeUser(ctx, model.EventUserUpdated, time.Now(), `
+		UPDATE users
+		SET name = $1, avatar_url = $2, description = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND deleted_at IS NULL
+		RETURNING `+userColumns+`
+	`, name, avatarUrl, description, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SuspendUser suspends the user until until, or indefinitely if it is nil,
+// and revokes their sessions. suspendedBy is nil when no admin is behind the
+// suspension. appId limits the suspension to one app unless it is 0. A
+// suspended user is suspended again with the new reason and expiry.
+func (r *UserRepository) SuspendUser(ctx context.Context, userId int64, appId int32, reason string, suspendedBy *int64, until *time.Time, now time.Time) (model.User, error) {
+	const op = "repository.SuspendUser"
+
+	user, err := r.changeUser(ctx, model.EventUserSuspended, now, `
+		WITH suspended AS (
+			UPDATE users
+			SET suspended_at = $1, suspended_until = $2, suspension_reason = $3, suspended_by = $4, updated_at = $1
+			WHERE id = $5 AND ($6 = 0 OR app_id = $6) AND deleted_at IS NULL
+			RETURNING `+userColumns+`
+		), revoked AS (
+			UPDATE sessions
+			SET revoked_at = $1
+			WHERE user_id IN (SELECT id FROM suspended) AND revoked_at IS NULL
+		)
+		SELECT `+userColumns+`
+		FROM suspended
+	`, now, until, reason, suspendedBy, userId, appId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UnsuspendUser lifts the suspension of the user, if any. appId limits it
+// to one app unless it is 0.
+func (r *UserRepository) UnsuspendUser(ctx context.Context, userId int64, appId int32) (model.User, error) {
+	const op = "repository.UnsuspendUser"
+
+	user, err := r.changeUser(ctx, model.EventUserUnsuspended, time.Now(), `
+		UPDATE users
+		SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, suspended_by = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ($2 = 0 OR app_id = $2) AND deleted_at IS NULL
+		RETURNING `+userColumns+`
+	`, userId, appId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser marks the user as deleted, revokes their sessions and returns
+// the user. The row is kept until it is purged, so the user can still be
+// restored.
+func (r *UserRepository) DeleteUser(ctx context.Context, userId int64, now time.Time) (model.User, error) {
+	const op = "repository.DeleteUser"
+
+	user, err := r.changeUser(ctx, model.EventUserDeleted, now, `
+		WITH deleted AS (
+			UPDATE users
+			SET deleted_at = $1, updated_at = $1
+			WHERE id = $2 AND deleted_at IS NULL
+			RETURNING `+userColumns+`
+		), revoked AS (
+			UPDATE sessions
+			SET revoked_at = $1
+			WHERE user_id IN (SELECT id FROM deleted) AND revoked_at IS NULL
+		)
+		SELECT `+userColumns+`
+		FROM deleted
+	`, now, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// RestoreUser undoes the deletion of a user deleted after deletedAfter and
+// not purged yet. appId limits the restore to one app unless it is 0.
+// Sessions revoked by the deletion stay revoked.
+func (r *UserRepository) RestoreUser(ctx context.Context, userId int64, appId int32, deletedAfter time.Time) (model.User, error) {
+	const op = "repository.RestoreUser"
+
+	user, err := r.changeUser(ctx, model.EventUserRestored, time.Now(), `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ($2 = 0 OR app_id = $2)
+			AND deleted_at IS NOT NULL AND deleted_at > $3 AND purged_at IS NULL
+		RETURNING `+userColumns+`
+	`, userId, appId, deletedAfter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		if isUniqueViolation(err) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// AnonymizeUsers scrubs the personal data and suspension details of users
+// deleted before deletedBefore, drops their sessions and roles, and marks
+// them purged. The rows stay so that ids referenced elsewhere keep
+// resolving.
+func (r *UserRepository) AnonymizeUsers(ctx context.Context, deletedBefore time.Time, now time.Time) (int64, error) {
+	const op = "repository.AnonymizeUsers"
+
+	var purged int64
+	err := r.db.QueryRowContext(ctx, `
+		WITH purged AS (
+			UPDATE users
+			SET name = 'Deleted user',
+				phone = 'deleted:' || id,
+				password = '',
+				avatar_url = NULL,
+				description = NULL,
+				phone_verified_at = NULL,
+				suspended_at = NULL,
+				suspended_until = NULL,
+				suspension_reason = NULL,
+				suspended_by = NULL,
+				purged_at = $1,
+				updated_at = $1
+			WHERE deleted_at IS NOT NULL AND deleted_at <= $2 AND purged_at IS NULL
+			RETURNING id
+		), sessions AS (
+			DELETE FROM sessions
+			WHERE user_id IN (SELECT id FROM purged)
+		), roles AS (
+			DELETE FROM user_roles
+			WHERE user_id IN (SELECT id FROM purged)
+		)
+		SELECT COUNT(*) FROM purged
+	`, now, deletedBefore).Scan(&purged)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// PurgeUsers removes users deleted before deletedBefore for good, along
+// with everything that references them.
+func (r *UserRepository) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const op = "repository.PurgeUsers"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+	`, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// ListUsers returns up to limit users matching filter with ids below
+// beforeId, newest first. A beforeId of 0 starts from the newest user.
+func (r *UserRepository) ListUsers(ctx context.Context, filter model.UserFilter, beforeId int64, limit int) ([]model.User, error) {
+	const op = "repository.ListUsers"
+
+	where, args := userConditions(filter)
+	if beforeId > 0 {
+		args = append(args, beforeId)
+		where += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) CountUsers(ctx context.Context, filter model.UserFilter) (int64, error) {
+	const op = "repository.CountUsers"
+
+	where, args := userConditions(filter)
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM users
+		WHERE `+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// userConditions builds the WHERE clause for filter. The query matches
+// names by trigram similarity and names and phones by substring, both of
+// which the trigram indexes serve.
+func userConditions(filter model.UserFilter) (string, []any) {
+	args := []any{filter.AppId}
+	conditions := []string{"app_id = $1", "deleted_at IS NULL"}
+
+	if filter.Query != "" {
+		args = append(args, filter.Query, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(name %% $%d OR name ILIKE $%d OR phone ILIKE $%d)", len(args)-1, len(args), len(args)))
+	}
+
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if filter.PhoneVerified != nil {
+		if *filter.PhoneVerified {
+			conditions = append(conditions, "phone_verified_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "phone_verified_at IS NULL")
+		}
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1
+			FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = users.id AND r.app_id = users.app_id AND r.name = $%d
+		)`, len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (model.User, error) {
+	var user model.User
+
+	err := row.Scan(
+		&user.Id,
+		&user.Name,
+		&user.Phone,
+		&user.AppId,
+		&user.AvatarUrl,
+		&user.Description,
+		&user.Balance,
+		&user.PhoneVerifiedAt,
+		&user.IsAdmin,
+		&user.CreatedAt,
+		&user.SuspendedAt,
+		&user.SuspendedUntil,
+		&user.SuspensionReason,
+		&user.SuspendedBy,
+	)
+
+	return user, err
+}
+
+// changeUser runs query, which changes a user and returns their columns,
+// in a transaction with queueing an event of eventType about the user.
+func (r *UserRepository) changeUser(ctx context.Context, eventType string, now time.Time, query string, args ...any) (model.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.User{}, err
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return user, err
+	}
+
+	if err := outbox.InsertEvent(ctx, tx, model.NewUserEvent(eventType, user, now)); err != nil {
+		return user, err
+	}
+
+	return user, tx.Commit()
 }
 
-func NewUserRepository (db *sql.DB) *UserRepository {
-    return &UserRepository{db: db}
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
 }
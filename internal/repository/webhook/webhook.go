@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	outbox "github.com/ei-jobs/auth-service/internal/repository/outbox"
+	"github.com/lib/pq"
+)
+
+var ErrDeliveryNotFound = apperr.New(apperr.NotFound, "WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery not found")
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_error, d.last_status_code, d.created_at, d.delivered_at`
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	const op = "repository.CreateSubscription"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (app_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, subscription.AppId, subscription.URL, subscription.Secret, pq.Array(subscription.EventTypes)).Scan(&subscription.Id, &subscription.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListSubscriptions returns the app's subscriptions with their secrets
+// still sealed.
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context, appId int32) ([]model.WebhookSubscription, error) {
+	const op = "repository.ListSubscriptions"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, app_id, url, secret, event_types, created_at
+		FROM webhook_subscriptions
+		WHERE app_id = $1
+		ORDER BY id
+	`, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subscriptions []model.WebhookSubscription
+	for rows.Next() {
+		var s model.WebhookSubscription
+		if err := rows.Scan(&s.Id, &s.AppId, &s.URL, &s.Secret, pq.Array(&s.EventTypes), &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes the subscription with its deliveries. It
+// reports whether the app had such a subscription.
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, appId int32, id int64) (bool, error) {
+	const op = "repository.DeleteSubscription"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM webhook_subscriptions
+		WHERE id = $1 AND app_id = $2
+	`, id, appId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// EnqueueDeliveries queues payload for every subscription of the event's
+// app that wants its type, and returns how many it queued. An event is
+// queued at most once per subscription, however often it is published.
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, event model.Event, payload []byte, now time.Time) (int64, error) {
+	const op = "repository.EnqueueDeliveries"
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at)
+		SELECT id, $1, $2, $3, $4
+		FROM webhook_subscriptions
+		WHERE app_id = $5 AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, event.Id, event.Type, payload, now, event.AppId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return queued, nil
+}
+
+// ClaimDeliveries leases up to limit pending deliveries that are due,
+// oldest first, until now plus lease, and loads their subscriptions.
+func (r *WebhookRepository) ClaimDeliveries(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]model.WebhookDelivery, error) {
+	const op = "repository.ClaimDeliveries"
+
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET locked_until = $1
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $2 AND (locked_until IS NULL OR locked_until <= $2)
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`, s.app_id, s.url, s.secret
+	`, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var (
+			delivery     model.WebhookDelivery
+			subscription model.WebhookSubscription
+		)
+
+		dest := append(deliveryDest(&delivery), &subscription.AppId, &subscription.URL, &subscription.Secret)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		subscription.Id = delivery.SubscriptionId
+		delivery.Subscription = &subscription
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// UPDATE ... RETURNING does not keep the order of the subquery.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id < deliveries[j].Id })
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, id int64, statusCode int, now time.Time) error {
+	const op = "repository.MarkDelivered"
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $1, last_error = NULL,
+			delivered_at = $2, locked_until = NULL
+		WHERE id = $3
+	`, statusCode, now, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt and leaves the delivery with status,
+// pending for another attempt at nextAttemptAt or dead. A zero statusCode
+// means there was no response.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, id int64, status string, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	const op = "repository.MarkFailed"
+
+	lastError = outbox.TruncateError(lastError)
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_status_code = $3, last_error = $4,
+			locked_until = NULL
+		WHERE id = $5
+	`, status, nextAttemptAt, code, lastError, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, appId int32, id int64) (model.WebhookDelivery, error) {
+	const op = "repository.GetDelivery"
+
+	var delivery model.WebhookDelivery
+	err := r.db.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.id = $1 AND s.app_id = $2
+	`, id, appId).Scan(deliveryDest(&delivery)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return delivery, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+		}
+		return delivery, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// ListDeliveries returns up to limit deliveries of the app with ids below
+// beforeId, newest first. A zero subscriptionId or empty status does not
+// filter, and a beforeId of 0 starts from the newest delivery.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, appId int32, subscriptionId int64, status string, beforeId int64, limit int) ([]model.WebhookDelivery, error) {
+	const op = "repository.ListDeliveries"
+
+	args := []any{appId}
+	conditions := []string{"s.app_id = $1"}
+
+	if subscriptionId != 0 {
+		args = append(args, subscriptionId)
+		conditions = append(conditions, fmt.Sprintf("d.subscription_id = $%d", len(args)))
+	}
+
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+
+	if beforeId > 0 {
+		args = append(args, beforeId)
+		conditions = append(conditions, fmt.Sprintf("d.id < $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY d.id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+		if err := rows.Scan(deliveryDest(&delivery)...); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// ReplayDelivery queues a delivered or dead delivery of the app again with
+// a fresh set of attempts. Pending deliveries are left alone.
+func (r *WebhookRepository) ReplayDelivery(ctx context.Context, appId int32, id int64, now time.Time) (model.WebhookDelivery, error) {
+	const op = "repository.ReplayDelivery"
+
+	var delivery model.WebhookDelivery
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = 'pending', attempts = 0, next_attempt_at = $1, locked_until = NULL
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id = $2 AND s.app_id = $3 AND d.status <> 'pending'
+		RETURNING `+deliveryColumns+`
+	`, now, id, appId).Scan(deliveryDest(&delivery)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return delivery, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+		}
+		return delivery, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+func deliveryDest(d *model.WebhookDelivery) []any {
+	return []any{
+		&d.Id,
+		&d.SubscriptionId,
+		&d.EventId,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastError,
+		&d.LastStatusCode,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	}
+}
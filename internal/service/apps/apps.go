@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
+	repository "github.com/ei-jobs/auth-service/internal/repository/apps"
+)
+
+type AppRepository interface {
+	CreateApp(ctx context.Context, app *model.App) error
+	GetApp(ctx context.Context, appId int32) (model.App, error)
+	ListApps(ctx context.Context, includeDisabled bool) ([]model.App, error)
+	UpdateApp(ctx context.Context, app *model.App) error
+	SetDisabled(ctx context.Context, appId int32, disabledAt *time.Time) error
+	DeleteApp(ctx context.Context, appId int32) (bool, error)
+	CountUsers(ctx context.Context, appId int32) (int64, error)
+	RotateSecret(ctx context.Context, appId int32, currentSecret string, secret string, previousSecret string, previousExpiresAt time.Time) (time.Time, error)
+	ReplaceSecrets(ctx context.Context, appId int32, currentSecret string, secret string, previousSecret *string) (bool, error)
+	UpdateSettings(ctx context.Context, appId int32, settings model.AppSettings, allowUnverifiedLogin bool) error
+}
+
+// SecretBox encrypts app secrets at rest. Open returns values that were
+// never sealed unchanged.
+type SecretBox interface {
+	Seal(plaintext string) (string, error)
+	Open(value string) (string, error)
+	IsSealed(value string) bool
+}
+
+var (
+	ErrInvalidName     = apperr.Invalid("name", "must be 1 to 255 characters").WithReason("INVALID_APP_NAME")
+	ErrAppNotFound     = apperr.New(apperr.NotFound, "APP_NOT_FOUND", "app not found")
+	ErrAppExists       = apperr.New(apperr.AlreadyExists, "APP_EXISTS", "app already exists")
+	ErrAppDisabled     = apperr.New(apperr.PermissionDenied, "APP_DISABLED", "app is disabled")
+	ErrAppHasUsers     = apperr.New(apperr.FailedPrecondition, "APP_HAS_USERS", "app has users, disable it instead")
+	ErrInvalidGrace    = apperr.Invalid("grace_period_seconds", "must not be negative").WithReason("INVALID_GRACE_PERIOD")
+	ErrRotationPending = apperr.New(apperr.FailedPrecondition, "ROTATION_PENDING", "previous secret is still in its grace period")
+	ErrInvalidSettings = apperr.New(apperr.InvalidArgument, "INVALID_SETTINGS", "invalid app settings")
+)
+
+// invalidSetting reports which setting was rejected. It matches
+// ErrInvalidSettings with errors.Is.
+func invalidSetting(field string, description string) error {
+	return ErrInvalidSettings.WithMessage(field + " " + description).WithFields(apperr.FieldViolation{
+		Field:       "settings." + field,
+		Description: description,
+	})
+}
+
+const secretSize = 32
+
+const (
+	maxAccessTTL  = 24 * time.Hour
+	maxRefreshTTL = 365 * 24 * time.Hour
+
+	minPasswordLength = 4
+	maxPasswordLength = 72
+)
+
+type AppService struct {
+	log         *slog.Logger
+	repository  AppRepository
+	secrets     SecretBox
+	gracePeriod time.Duration
+}
+
+func NewAppService(log *slog.Logger, repository AppRepository, secrets SecretBox, gracePeriod time.Duration) *AppService {
+	return &AppService{
+		log:         log,
+		repository:  repository,
+		secrets:     secrets,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// ActiveApp loads an app for issuing or verifying its tokens, with its
+// secrets decrypted. Disabled apps yield ErrAppDisabled.
+func (s *AppService) ActiveApp(ctx context.Context, appId int32) (model.App, error) {
+	const op = "appservice.ActiveApp"
+
+	app, err := s.load(ctx, appId)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.Disabled() {
+		return model.App{}, fmt.Errorf("%s: %w", op, ErrAppDisabled)
+	}
+
+	return app, nil
+}
+
+func (s *AppService) GetApp(ctx context.Context, appId int32) (model.App, error) {
+	const op = "appservice.GetApp"
+
+	app, err := s.load(ctx, appId)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// CreateApp registers an app and returns it with its generated secret, which
+// is not retrievable afterwards.
+func (s *AppService) CreateApp(ctx context.Context, name string, allowUnverifiedLogin bool) (model.App, error) {
+	const op = "appservice.CreateApp"
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return model.App{}, fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+
+	secret, err := opaque.New(secretSize)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app := model.App{
+		Name:                 name,
+		Secret:               sealed,
+		AllowUnverifiedLogin: allowUnverifiedLogin,
+	}
+	if err := s.repository.CreateApp(ctx, &app); err != nil {
+		if errors.Is(err, repository.ErrAppExists) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("app created", slog.Int("app_id", app.Id), slog.String("name", app.Name))
+
+	app.Secret = secret
+
+	return app, nil
+}
+
+func (s *AppService) ListApps(ctx context.Context, includeDisabled bool) ([]model.App, error) {
+	const op = "appservice.ListApps"
+
+	apps, err := s.repository.ListApps(ctx, includeDisabled)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+func (s *AppService) UpdateApp(ctx context.Context, appId int32, name string, allowUnverifiedLogin bool) (model.App, error) {
+	const op = "appservice.UpdateApp"
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return model.App{}, fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+
+	app, err := s.load(ctx, appId)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.Name = name
+	app.AllowUnverifiedLogin = allowUnverifiedLogin
+
+	if err := s.repository.UpdateApp(ctx, &app); err != nil {
+		if errors.Is(err, repository.ErrAppExists) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		if errors.Is(err, repository.ErrAppNotFound) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// DisableApp stops the app from logging users in and makes its tokens fail
+// verification, without deleting anything.
+func (s *AppService) DisableApp(ctx context.Context, appId int32) (model.App, error) {
+	const op = "appservice.DisableApp"
+
+	now := time.Now()
+
+	app, err := s.setDisabled(ctx, appId, &now)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Warn("app disabled", slog.Int("app_id", app.Id))
+
+	return app, nil
+}
+
+func (s *AppService) EnableApp(ctx context.Context, appId int32) (model.App, error) {
+	const op = "appservice.EnableApp"
+
+	app, err := s.setDisabled(ctx, appId, nil)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("app enabled", slog.Int("app_id", app.Id))
+
+	return app, nil
+}
+
+// DeleteApp removes an app that has no users. Apps with users can only be
+// disabled.
+func (s *AppService) DeleteApp(ctx context.Context, appId int32) error {
+	const op = "appservice.DeleteApp"
+
+	users, err := s.repository.CountUsers(ctx, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if users > 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppHasUsers)
+	}
+
+	deleted, err := s.repository.DeleteApp(ctx, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !deleted {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	s.log.Warn("app deleted", slog.Int("app_id", int(appId)))
+
+	return nil
+}
+
+// RotateSecret replaces the app's secret and returns the app with the new
+// one. The old secret keeps verifying tokens for gracePeriod, or the
+// configured default when zero; it should outlive the access token TTL. A
+// rotation is refused while the previous one is still in its grace period,
+// since that would drop a secret tokens may still be signed with.
+func (s *AppService) RotateSecret(ctx context.Context, appId int32, gracePeriod time.Duration) (model.App, error) {
+	const op = "appservice.RotateSecret"
+
+	if gracePeriod < 0 {
+		return model.App{}, fmt.Errorf("%s: %w", op, ErrInvalidGrace)
+	}
+	if gracePeriod == 0 {
+		gracePeriod = s.gracePeriod
+	}
+
+	stored, err := s.repository.GetApp(ctx, appId)
+	if err != nil {
+		if errors.Is(err, repository.ErrAppNotFound) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+
+	if stored.PreviousSecretValid(now) {
+		return model.App{}, fmt.Errorf("%s: %w", op, ErrRotationPending)
+	}
+
+	current, err := s.secrets.Open(stored.Secret)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	previous, err := s.secrets.Seal(current)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := opaque.New(secretSize)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expiresAt := now.Add(gracePeriod)
+
+	rotatedAt, err := s.repository.RotateSecret(ctx, appId, stored.Secret, sealed, previous, expiresAt)
+	if err != nil {
+		if errors.Is(err, repository.ErrSecretChanged) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrRotationPending)
+		}
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Warn("app secret rotated",
+		slog.Int("app_id", stored.Id),
+		slog.Time("previous_secret_expires_at", expiresAt),
+	)
+
+	app := stored
+	app.Secret = secret
+	app.PreviousSecret = &current
+	app.PreviousSecretExpiresAt = &expiresAt
+	app.SecretRotatedAt = &rotatedAt
+
+	return app, nil
+}
+
+// UpdateSettings replaces the app's settings. requirePhoneVerification is
+// stored as the app's allow_unverified_login flag.
+func (s *AppService) UpdateSettings(ctx context.Context, appId int32, settings model.AppSettings, requirePhoneVerification bool) (model.App, error) {
+	const op = "appservice.UpdateSettings"
+
+	if err := validateSettings(settings); err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repository.UpdateSettings(ctx, appId, settings, !requirePhoneVerification); err != nil {
+		if errors.Is(err, repository.ErrAppNotFound) {
+			return model.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := s.load(ctx, appId)
+	if err != nil {
+		return model.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("app settings updated", slog.Int("app_id", app.Id))
+
+	return app, nil
+}
+
+func validateSettings(settings model.AppSettings) error {
+	if settings.AccessTTL < 0 || settings.AccessTTL > maxAccessTTL {
+		return invalidSetting("access_ttl_seconds", fmt.Sprintf("must be between 0 and %d", int64(maxAccessTTL.Seconds())))
+	}
+
+	if settings.RefreshTTL < 0 || settings.RefreshTTL > maxRefreshTTL {
+		return invalidSetting("refresh_ttl_seconds", fmt.Sprintf("must be between 0 and %d", int64(maxRefreshTTL.Seconds())))
+	}
+
+	if settings.AccessTTL > 0 && settings.RefreshTTL > 0 && settings.AccessTTL > settings.RefreshTTL {
+		return invalidSetting("access_ttl_seconds", "must not exceed refresh_ttl_seconds")
+	}
+
+	if settings.PasswordMinLength != 0 && (settings.PasswordMinLength < minPasswordLength || settings.PasswordMinLength > maxPasswordLength) {
+		return invalidSetting("password_min_length", fmt.Sprintf("must be between %d and %d", minPasswordLength, maxPasswordLength))
+	}
+
+	if settings.PasswordMaxLength != 0 && (settings.PasswordMaxLength < minPasswordLength || settings.PasswordMaxLength > maxPasswordLength) {
+		return invalidSetting("password_max_length", fmt.Sprintf("must be between %d and %d", minPasswordLength, maxPasswordLength))
+	}
+
+	if settings.PasswordMinLength != 0 && settings.PasswordMaxLength != 0 && settings.PasswordMinLength > settings.PasswordMaxLength {
+		return invalidSetting("password_min_length", "must not exceed password_max_length")
+	}
+
+	for _, method := range settings.LoginMethods {
+		if method != model.LoginMethodPassword && method != model.LoginMethodPhoneCode {
+			return invalidSetting("login_methods", fmt.Sprintf("has unknown method %q", method))
+		}
+	}
+
+	return nil
+}
+
+// load reads the app and decrypts its secrets. Secrets still stored in
+// plaintext are encrypted on the way, so they migrate as apps are used.
+func (s *AppService) load(ctx context.Context, appId int32) (model.App, error) {
+	app, err := s.repository.GetApp(ctx, appId)
+	if err != nil {
+		if errors.Is(err, repository.ErrAppNotFound) {
+			return model.App{}, ErrAppNotFound
+		}
+		return model.App{}, err
+	}
+
+	stored := app.Secret
+	migrate := false
+
+	app.Secret, err = s.open(app.Secret, &migrate)
+	if err != nil {
+		return model.App{}, err
+	}
+
+	if app.PreviousSecret != nil {
+		previous, err := s.open(*app.PreviousSecret, &migrate)
+		if err != nil {
+			return model.App{}, err
+		}
+		app.PreviousSecret = &previous
+	}
+
+	if migrate {
+		s.encryptSecrets(ctx, app, stored)
+	}
+
+	return app, nil
+}
+
+func (s *AppService) open(value string, migrate *bool) (string, error) {
+	if !s.secrets.IsSealed(value) {
+		*migrate = true
+	}
+
+	return s.secrets.Open(value)
+}
+
+// encryptSecrets stores app's plaintext secrets sealed. Failures are only
+// logged: the app is usable either way and the next load retries.
+func (s *AppService) encryptSecrets(ctx context.Context, app model.App, stored string) {
+	const op = "appservice.encryptSecrets"
+
+	log := s.log.With(slog.String("op", op), slog.Int("app_id", app.Id))
+
+	secret, err := s.secrets.Seal(app.Secret)
+	if err != nil {
+		log.Error("failed to encrypt app secret", slog.String("error", err.Error()))
+		return
+	}
+
+	var previous *string
+	if app.PreviousSecret != nil {
+		sealed, err := s.secrets.Seal(*app.PreviousSecret)
+		if err != nil {
+			log.Error("failed to encrypt previous app secret", slog.String("error", err.Error()))
+			return
+		}
+		previous = &sealed
+	}
+
+	replaced, err := s.repository.ReplaceSecrets(ctx, int32(app.Id), stored, secret, previous)
+	if err != nil {
+		log.Error("failed to store encrypted app secret", slog.String("error", err.Error()))
+		return
+	}
+
+	if replaced {
+		log.Info("encrypted plaintext app secret")
+	}
+}
+
+func (s *AppService) setDisabled(ctx context.Context, appId int32, disabledAt *time.Time) (model.App, error) {
+	if err := s.repository.SetDisabled(ctx, appId, disabledAt); err != nil {
+		if errors.Is(err, repository.ErrAppNotFound) {
+			return model.App{}, ErrAppNotFound
+		}
+		return model.App{}, err
+	}
+
+	return s.load(ctx, appId)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/principal"
+	"github.com/ei-jobs/auth-service/internal/lib/requestinfo"
+)
+
+type AuditRepository interface {
+	AppendEvent(ctx context.Context, event *model.AuditEvent) error
+	ListEvents(ctx context.Context, filter model.AuditFilter, beforeId int64, limit int) ([]model.AuditEvent, error)
+	ListChain(ctx context.Context, afterId int64, limit int) ([]model.AuditEvent, error)
+}
+
+var ErrInvalidPageToken = apperr.Invalid("page_token", "is invalid").WithReason("INVALID_PAGE_TOKEN")
+
+const (
+	defaultPageSize      = 50
+	maxPageSize          = 200
+	verifyBatchSize      = 1000
+	verificationInterval = time.Hour
+	maxUserAgentLength   = 512
+	recordTimeout        = 5 * time.Second
+)
+
+// AuditService keeps the audit log of security-relevant events. Events are
+// hash-chained, and RunVerification walks the chain to detect tampering.
+type AuditService struct {
+	log        *slog.Logger
+	repository AuditRepository
+
+	// verifiedId and verifiedHash mark how far RunVerification got, so
+	// each run only checks events added since.
+	verifiedId   int64
+	verifiedHash string
+}
+
+func NewAuditService(log *slog.Logger, repository AuditRepository) *AuditService {
+	return &AuditService{
+		log:        log,
+		repository: repository,
+	}
+}
+
+// Record appends the event to the audit log. The actor defaults to the
+// caller of the request, and the IP and user agent are taken from the
+// request. Failing to record is logged rather than failing the operation
+// being audited, and a cancelled request still gets its event recorded.
+func (s *AuditService) Record(ctx context.Context, event model.AuditEvent) {
+	const op = "auditservice.Record"
+
+	if event.ActorUserId == 0 && event.ActorService == "" {
+		if p, ok := principal.FromContext(ctx); ok {
+			event.ActorUserId = p.UserId
+			event.ActorService = p.Service
+		}
+	}
+
+	info := requestinfo.FromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	if len(event.UserAgent) > maxUserAgentLength {
+		event.UserAgent = strings.ToValidUTF8(event.UserAgent[:maxUserAgentLength], "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	if err := s.repository.AppendEvent(ctx, &event); err != nil {
+		s.log.Error("failed to record audit event",
+			slog.String("op", op),
+			slog.String("type", event.Type),
+			slog.Int64("target_user_id", event.TargetUserId),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// ListEvents returns a page of events matching filter, newest first.
+func (s *AuditService) ListEvents(ctx context.Context, filter model.AuditFilter, pageSize int, pageToken string) ([]model.AuditEvent, string, error) {
+	const op = "auditservice.ListEvents"
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var beforeId int64
+	if pageToken != "" {
+		id, err := decodePageToken(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+		}
+		beforeId = id
+	}
+
+	// One extra event tells whether there is a next page.
+	events, err := s.repository.ListEvents(ctx, filter, beforeId, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nextPageToken string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		nextPageToken = encodePageToken(events[pageSize-1].Id)
+	}
+
+	return events, nextPageToken, nil
+}
+
+// RunVerification periodically checks the events added since the last run
+// against the hash chain.
+func (s *AuditService) RunVerification(ctx context.Context) {
+	const op = "auditservice.RunVerification"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(verificationInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.verify(ctx); err != nil {
+			log.Error("audit log verification failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// verify checks that every event after the last verified one links to its
+// predecessor and hashes to its stored hash. It stops at the first broken
+// event, which is reported again on every run until it is dealt with.
+func (s *AuditService) verify(ctx context.Context) error {
+	const op = "auditservice.verify"
+
+	for {
+		events, err := s.repository.ListChain(ctx, s.verifiedId, verifyBatchSize)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range events {
+			if event.PrevHash != s.verifiedHash || event.ComputeHash() != event.Hash {
+				return fmt.Errorf("%s: audit chain broken at event %d", op, event.Id)
+			}
+
+			s.verifiedId = event.Id
+			s.verifiedHash = event.Hash
+		}
+
+		if len(events) < verifyBatchSize {
+			return nil
+		}
+	}
+}
+
+func encodePageToken(lastId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastId, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid page token")
+	}
+
+	return id, nil
+}
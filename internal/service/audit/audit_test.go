@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+// fakeAuditRepository chains events the way the database repository does.
+type fakeAuditRepository struct {
+	events []model.AuditEvent
+}
+
+func (r *fakeAuditRepository) AppendEvent(_ context.Context, event *model.AuditEvent) error {
+	event.Id = int64(len(r.events) + 1)
+	if len(r.events) > 0 {
+		event.PrevHash = r.events[len(r.events)-1].Hash
+	}
+	event.CreatedAt = time.Date(2026, 1, 1, 12, 0, len(r.events), 0, time.UTC)
+	event.Hash = event.ComputeHash()
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeAuditRepository) ListEvents(context.Context, model.AuditFilter, int64, int) ([]model.AuditEvent, error) {
+	return nil, nil
+}
+
+func (r *fakeAuditRepository) ListChain(_ context.Context, afterId int64, limit int) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+	for _, event := range r.events {
+		if event.Id > afterId && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func newTestAuditService(t *testing.T, n int) (*AuditService, *fakeAuditRepository) {
+	t.Helper()
+
+	repo := &fakeAuditRepository{}
+	s := NewAuditService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo)
+	for i := 0; i < n; i++ {
+		s.Record(context.Background(), model.AuditEvent{Type: model.AuditLogin, AppId: 1, TargetUserId: int64(i + 1)})
+	}
+
+	return s, repo
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		tamper    func(repo *fakeAuditRepository)
+		wantEvent string
+	}{
+		{
+			name:   "intact chain",
+			tamper: func(repo *fakeAuditRepository) {},
+		},
+		{
+			name:      "event changed",
+			tamper:    func(repo *fakeAuditRepository) { repo.events[2].TargetUserId = 99 },
+			wantEvent: "event 3",
+		},
+		{
+			name: "event changed and rehashed",
+			tamper: func(repo *fakeAuditRepository) {
+				repo.events[2].Type = model.AuditUserDeleted
+				repo.events[2].Hash = repo.events[2].ComputeHash()
+			},
+			wantEvent: "event 4",
+		},
+		{
+			name:      "event removed",
+			tamper:    func(repo *fakeAuditRepository) { repo.events = append(repo.events[:1], repo.events[2:]...) },
+			wantEvent: "event 3",
+		},
+		{
+			name:      "first event removed",
+			tamper:    func(repo *fakeAuditRepository) { repo.events = repo.events[1:] },
+			wantEvent: "event 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestAuditService(t, 5)
+			tt.tamper(repo)
+
+			err := s.verify(context.Background())
+			if tt.wantEvent == "" {
+				if err != nil {
+					t.Fatalf("verify() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantEvent) {
+				t.Fatalf("verify() error = %v, want chain broken at %s", err, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestVerifyResumes(t *testing.T) {
+	s, repo := newTestAuditService(t, 3)
+	ctx := context.Background()
+
+	if err := s.verify(ctx); err != nil {
+		t.Fatalf("verify() error = %v", err)
+	}
+	if s.verifiedId != 3 {
+		t.Fatalf("verifiedId = %d, want 3", s.verifiedId)
+	}
+
+	// Events already verified are not checked again.
+	repo.events[0].TargetUserId = 99
+
+	s.Record(ctx, model.AuditEvent{Type: model.AuditLogin, AppId: 1})
+	if err := s.verify(ctx); err != nil {
+		t.Fatalf("verify() error = %v", err)
+	}
+	if s.verifiedId != 4 {
+		t.Errorf("verifiedId = %d, want 4", s.verifiedId)
+	}
+
+	repo.events = append(repo.events, model.AuditEvent{Id: 5, Type: model.AuditLogin, PrevHash: "forged"})
+	if err := s.verify(ctx); err == nil {
+		t.Fatal("verify() accepted an event with a forged previous hash")
+	}
+	if s.verifiedId != 4 {
+		t.Errorf("verifiedId = %d after a broken event, want 4", s.verifiedId)
+	}
+}
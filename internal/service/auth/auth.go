@@ -2,280 +2,567 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
 	"github.com/ei-jobs/auth-service/internal/domain/model"
 	"github.com/ei-jobs/auth-service/internal/lib/jwt"
-	ssov1 "github.com/ei-jobs/protos/gen/go/sso"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/ei-jobs/auth-service/internal/lib/password"
+	"github.com/ei-jobs/auth-service/internal/lib/requestinfo"
+	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+	"github.com/ei-jobs/auth-service/pkg/jwk"
 )
 
 type AuthRepository interface {
 	StoreUser(ctx context.Context, phone string, name string, appId int32, password []byte) (int64, error)
-    UpdateUser(ctx context.Context, user *model.User) (*model.User, error)
-    DeleteUser(ctx context.Context, user_id int64) (bool, error)
 	GetUserByPhone(ctx context.Context, phone string, app_id int32) (model.User, error)
-	UpdatePassword(ctx context.Context, phone string, app_id int32, password []byte) (model.User, error)
-    GetAppById(ctx context.Context, app_id int32) (model.App, error)
-    GetUserById(ctx context.Context, user_id int64) (*model.User, error)
+	UpdatePassword(ctx context.Context, phone string, app_id int32, password []byte, verifyPhone bool) (model.User, error)
+	GetUserById(ctx context.Context, user_id int64) (*model.User, error)
+	StoreRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id int64) (bool, error)
+	CreateSession(ctx context.Context, session *model.Session) error
+	GetSession(ctx context.Context, id string) (model.Session, error)
+	TouchSession(ctx context.Context, id string, expiresAt time.Time) error
+	ListActiveSessions(ctx context.Context, userId int64, appId int32) ([]model.Session, error)
+	RevokeSession(ctx context.Context, id string) (bool, error)
+	RevokeUserSessions(ctx context.Context, userId int64, appId int32, exceptId string) (int64, error)
+	MarkPhoneVerified(ctx context.Context, user_id int64) error
+	ReplacePasswordHash(ctx context.Context, user_id int64, oldHash []byte, newHash []byte) (bool, error)
 }
 
+type KeyProvider interface {
+	SigningKey(ctx context.Context, app *model.App) (*jwt.Key, error)
+	VerificationKey(ctx context.Context, kid string, app *model.App) (*jwt.Key, error)
+	JWKS(ctx context.Context, appId int32) (jwk.Set, error)
+}
+
+type OTPService interface {
+	Send(ctx context.Context, phone string, appId int32, purpose string) error
+	Throttle(ctx context.Context, phone string, appId int32, purpose string) error
+	Verify(ctx context.Context, phone string, appId int32, purpose string, code string) error
+}
+
+// AppProvider loads enabled apps with their secrets decrypted.
+type AppProvider interface {
+	ActiveApp(ctx context.Context, appId int32) (model.App, error)
+}
+
+// AccessProvider resolves the roles and permissions a user holds in an app,
+// which are embedded into their access tokens.
+type AccessProvider interface {
+	UserAccess(ctx context.Context, userId int64, appId int32) (roles []string, permissions []string, err error)
+}
+
+// LoginGuard throttles password guessing per account and client IP.
+type LoginGuard interface {
+	Check(ctx context.Context, phone string, appId int32, ip string) error
+	RecordFailure(ctx context.Context, phone string, appId int32, ip string) error
+	RecordSuccess(ctx context.Context, phone string, appId int32) error
+	Unlock(ctx context.Context, phone string, appId int32) (bool, error)
+}
+
+// PasswordHasher hashes passwords and verifies stored hashes. needsRehash
+// reports a matching hash made with an outdated algorithm or parameters.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (ok bool, needsRehash bool, err error)
+}
+
+// Auditor records security-relevant events in the audit log.
+type Auditor interface {
+	Record(ctx context.Context, event model.AuditEvent)
+}
+
+// rehashTimeout bounds the background rehash after a login.
+const rehashTimeout = 10 * time.Second
+
+var (
+	ErrInvalidCredentials    = apperr.New(apperr.Unauthenticated, "INVALID_CREDENTIALS", "invalid phone or password")
+	ErrWeakPassword          = apperr.New(apperr.InvalidArgument, "PASSWORD_POLICY_VIOLATION", "password does not satisfy policy")
+	ErrUserNotFound          = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+	ErrUserExists            = apperr.New(apperr.AlreadyExists, "USER_EXISTS", "user already exists")
+	ErrInvalidOldPassword    = apperr.New(apperr.PermissionDenied, "INVALID_OLD_PASSWORD", "old password is incorrect")
+	ErrPhoneNotVerified      = apperr.New(apperr.FailedPrecondition, "PHONE_NOT_VERIFIED", "phone is not verified")
+	ErrLoginMethodNotAllowed = apperr.New(apperr.PermissionDenied, "LOGIN_METHOD_NOT_ALLOWED", "login method is not allowed for this app")
+)
+
 type AuthService struct {
-	log        *slog.Logger
-	repository AuthRepository
+	log            *slog.Logger
+	repository     AuthRepository
+	apps           AppProvider
+	keys           KeyProvider
+	otp            OTPService
+	access         AccessProvider
+	lockout        LoginGuard
+	hasher         PasswordHasher
+	audit          Auditor
+	passwordPolicy password.Policy
+	accessTTL      time.Duration
+	refreshTTL     time.Duration
+	tokenOptions   []jwt.Option
+
+	dummyHashOnce sync.Once
+	dummyHash     []byte
 }
 
-func NewAuthService(log *slog.Logger, repository AuthRepository) *AuthService {
+func NewAuthService(log *slog.Logger, repository AuthRepository, apps AppProvider, keys KeyProvider, otp OTPService, access AccessProvider, lockout LoginGuard, hasher PasswordHasher, audit Auditor, passwordPolicy password.Policy, accessTTL time.Duration, refreshTTL time.Duration, compactRBAC bool) *AuthService {
+	var tokenOptions []jwt.Option
+	if compactRBAC {
+		tokenOptions = append(tokenOptions, jwt.WithCompactRBAC())
+	}
+
 	return &AuthService{
-		log:        log,
-		repository: repository,
+		log:            log,
+		repository:     repository,
+		apps:           apps,
+		keys:           keys,
+		otp:            otp,
+		access:         access,
+		lockout:        lockout,
+		hasher:         hasher,
+		audit:          audit,
+		passwordPolicy: passwordPolicy,
+		accessTTL:      accessTTL,
+		refreshTTL:     refreshTTL,
+		tokenOptions:   tokenOptions,
+	}
+}
+
+func (s *AuthService) Login(ctx context.Context, phone string, password string, appId int32) (model.TokenPair, error) {
+	const op = "authservice.Login"
+
+	ip := requestinfo.FromContext(ctx).IP
+
+	if err := s.lockout.Check(ctx, phone, appId, ip); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repository.GetUserByPhone(ctx, phone, appId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.verifyDummyHash(password)
+			s.loginFailed(ctx, appId, 0, phone, model.LoginMethodPassword, "unknown_user")
+			if err := s.lockout.RecordFailure(ctx, phone, appId, ip); err != nil {
+				return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+			}
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ok, needsRehash, err := s.hasher.Verify(user.PassHash, password)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !ok {
+		s.loginFailed(ctx, appId, user.Id, phone, model.LoginMethodPassword, "invalid_password")
+
+		if err := s.lockout.RecordFailure(ctx, phone, appId, ip); err != nil {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !app.AllowsLoginMethod(model.LoginMethodPassword) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrLoginMethodNotAllowed.WithMetadata("method", model.LoginMethodPassword))
+	}
+
+	if !user.PhoneVerified() && !app.AllowUnverifiedLogin {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrPhoneNotVerified)
+	}
+
+	tokens, err := s.issueTokens(ctx, &user, &app, "")
+	if err != nil {
+		if errors.Is(err, ErrAccountSuspended) {
+			s.loginFailed(ctx, appId, user.Id, phone, model.LoginMethodPassword, "suspended")
+		}
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Only a login that went through clears the failures and touches the
+	// stored hash.
+	if err := s.lockout.RecordSuccess(ctx, phone, appId); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if needsRehash {
+		go s.rehashPassword(context.WithoutCancel(ctx), user.Id, user.PassHash, password)
+	}
+
+	s.loggedIn(ctx, &user, model.LoginMethodPassword)
+
+	return tokens, nil
+}
+
+// Register stores a new user. When the app does not let unverified users log
+// in, no tokens are issued; a verification code is sent instead and
+// verificationRequired is set.
+func (s *AuthService) Register(ctx context.Context, name string, phone string, password string, appId int32) (tokens model.TokenPair, verificationRequired bool, err error) {
+	const op = "authservice.Register"
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.passwordPolicyFor(&app).Validate(password, phone, name); err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, policyError("password", err))
+	}
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user_id, err := s.repository.StoreUser(ctx, phone, name, appId, passHash)
+	if err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:         model.AuditRegister,
+		AppId:        appId,
+		ActorUserId:  user_id,
+		TargetUserId: user_id,
+	})
+
+	user, err := s.repository.GetUserById(ctx, user_id)
+	if err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	if !user.PhoneVerified() && !app.AllowUnverifiedLogin {
+		if err := s.otp.Send(ctx, phone, appId, model.OTPPurposePhoneVerification); err != nil {
+			s.log.Error("failed to send phone verification code", slog.String("op", op), slog.String("error", err.Error()))
+		}
+		return model.TokenPair{}, true, nil
+	}
+
+	tokens, err = s.issueTokens(ctx, user, &app, "")
+	if err != nil {
+		return model.TokenPair{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, false, nil
+}
+
+// RequestPasswordReset sends a reset code to the phone. Unknown phones are
+// not reported and are throttled like known ones, so that the RPC cannot be
+// used to probe for accounts.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, phone string, appId int32) error {
+	const op = "authservice.RequestPasswordReset"
+
+	if _, err := s.repository.GetUserByPhone(ctx, phone, appId); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.log.Info("password reset requested for unknown phone", slog.Int("app_id", int(appId)))
+			if err := s.otp.Throttle(ctx, phone, appId, model.OTPPurposePasswordReset); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.otp.Send(ctx, phone, appId, model.OTPPurposePasswordReset); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset sets a new password once code checks out. The
+// password is checked against the policy first, so that a rejected one
+// leaves the code usable for another try.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, phone string, appId int32, code string, password string) (model.TokenPair, error) {
+	const op = "authservice.ConfirmPasswordReset"
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Unknown phones go through the same checks, so that the answer does
+	// not tell whether the phone is registered. No code was sent to them,
+	// so Verify turns them away.
+	user, err := s.repository.GetUserByPhone(ctx, phone, appId)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.passwordPolicyFor(&app).Validate(password, phone, user.Name); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, policyError("new_password", err))
+	}
+
+	if err := s.otp.Verify(ctx, phone, appId, model.OTPPurposePasswordReset, code); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Id == 0 {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	// The code reached the phone, which proves ownership just as well as a
+	// verification code would.
+	tokens, err := s.setPassword(ctx, &user, &app, password, model.AuditPasswordReset, true)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
+
+	return tokens, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, phone string, password string, appId int32) (string, error) {
-    const op = "authservice.Login"
+// ChangePassword sets a new password for the owner of token after checking
+// the old one. The caller's and all other sessions are revoked and the caller
+// continues in a new session.
+func (s *AuthService) ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string) (model.TokenPair, error) {
+	const op = "authservice.ChangePassword"
 
-    user, err := s.repository.GetUserByPhone(ctx, phone, appId)
-    if err != nil {
-        return "", fmt.Errorf("%s: %w", op, err) 
-    }
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-    if err = bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		s.log.Info("invalid credentials", slog.String("error", err.Error())) 
+	user, err := s.repository.GetUserById(ctx, claims.Uid)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, userError(err))
+	}
 
-		return "", fmt.Errorf("%s: %s", op, "Invalid credentials")
+	ok, _, err := s.hasher.Verify(user.PassHash, oldPassword)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-    app, err := s.repository.GetAppById(ctx, appId)
-    if err != nil {
-        return "", fmt.Errorf("%s: %w", op, err)
-    }
+	if !ok {
+		s.log.Info("invalid old password", slog.Int64("user_id", user.Id))
 
-    token, err := jwt.NewToken(&user, &app, time.Hour * 24 * 365)
-	return token, nil
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidOldPassword)
+	}
+
+	if oldPassword == newPassword {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, policyError("new_password", &password.PolicyError{
+			Violations: []password.Violation{{Rule: password.RuleReused, Message: "must differ from the old password"}},
+		}))
+	}
+
+	app, err := s.apps.ActiveApp(ctx, user.AppId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.passwordPolicyFor(&app).Validate(newPassword, user.Phone, user.Name); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, policyError("new_password", err))
+	}
+
+	tokens, err := s.setPassword(ctx, user, &app, newPassword, model.AuditPasswordChanged, false)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
 }
 
-func (s *AuthService) Register(ctx context.Context, name string, phone string, password string, appId int32) (string, error) {
-    const op = "authservice.Regsiter"
-
-    passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost) 
-    if err != nil {
-        return "", fmt.Errorf("%s: %w", op, err)
-    }
-
-    user_id, err := s.repository.StoreUser(ctx, phone, name, appId, passHash)
-    if err != nil {
-        return "", fmt.Errorf("%s: %w", op, err)
-    } 
-    
-    var wg sync.WaitGroup
-    errChan := make(chan error, 2)
-    userChan := make(chan *model.User, 1)
-    appChan := make(chan *model.App, 1)
-    
-    wg.Add(1)
-    go func() {
-        defer wg.Done()
-        user, err := s.repository.GetUserById(ctx, user_id)
-        if err != nil {
-            errChan <- err
-            return
-        }
-        userChan <- user
-    }()
-
-    wg.Add(1)
-    go func() {
-        defer wg.Done()
-        app, err := s.repository.GetAppById(ctx, appId)
-        if err != nil {
-            errChan <- err
-            return
-        }
-        appChan <- &app
-    }()
-
-    go func() {
-        wg.Wait()
-        close(errChan)
-        close(userChan)
-        close(appChan)
-    }()
-
-    var user *model.User
-    var app *model.App
-    for {
-        select {
-        case err := <-errChan:
-            if err != nil {
-                return "", err
-            }
-        case u := <-userChan:
-            if u != nil {
-                user = u
-            }
-        case a := <-appChan:
-            if a != nil {
-                app = a
-            }
-        case <-ctx.Done():
-            return "", fmt.Errorf("%s: context cancelled", op)
-        }
-
-        if user != nil && app != nil {
-            break
-        }
-    }
-
-    token, err := jwt.NewToken(user, app, time.Hour * 24 * 365)
-
-	return token, nil
+// UnlockAccount clears failed logins and any lockout of the user, who must
+// belong to appId. It reports whether the account had any.
+func (s *AuthService) UnlockAccount(ctx context.Context, appId int32, userId int64) (bool, error) {
+	const op = "authservice.UnlockAccount"
+
+	user, err := s.repository.GetUserById(ctx, userId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	if user.AppId != appId {
+		return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	unlocked, err := s.lockout.Unlock(ctx, user.Phone, appId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return unlocked, nil
 }
 
-func (s *AuthService) ForgetPassword(ctx context.Context, phone string, password string, app_id int32) (string, error) {
-    const op = "authservice.ForgetPassword"
+// setPassword stores a password that passed the app's policy, revokes
+// every session of the user and starts a new one. With verifyPhone the
+// phone is marked verified in the same update. The change is audited as
+// eventType.
+func (s *AuthService) setPassword(ctx context.Context, current *model.User, app *model.App, password string, eventType string, verifyPhone bool) (model.TokenPair, error) {
+	const op = "authservice.setPassword"
 
-    //ToDo: implement to logic sending the sms code and receiving it
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-    return s.ChangePassword(ctx, phone, password, app_id)
+	user, err := s.repository.UpdatePassword(ctx, current.Phone, current.AppId, passHash, verifyPhone)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:         eventType,
+		AppId:        user.AppId,
+		ActorUserId:  user.Id,
+		TargetUserId: user.Id,
+	})
+
+	if _, err := s.repository.RevokeUserSessions(ctx, user.Id, user.AppId, ""); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := s.issueTokens(ctx, &user, app, "")
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
 }
 
-func (s *AuthService) UpdateUser(ctx context.Context, user *ssov1.User) (*ssov1.User, error) {
-    userRequest := &model.User{
-        Id:     user.GetId(),
-        Name:   user.GetName(),
-        Phone:  user.GetPhone(),
-        AppId:  user.GetAppId(),
-        AvatarUrl: stringToPointer(user.GetAvatarUrl()),
-        Description: stringToPointer(user.GetDescription()),
-    }
-
-    newUser, err := s.repository.UpdateUser(ctx, userRequest)
-    if err != nil {
-        return nil, err
-    }
-
-    return &ssov1.User{
-        Id:     newUser.Id,
-        Name:   newUser.Name,
-        Phone:  newUser.Phone,
-        AppId:  newUser.AppId,
-        Description: ifNilReturnEmpty(newUser.Description),
-        AvatarUrl: ifNilReturnEmpty(newUser.AvatarUrl),
-    }, nil
+// loggedIn records a successful login of user.
+func (s *AuthService) loggedIn(ctx context.Context, user *model.User, method string) {
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:         model.AuditLogin,
+		AppId:        user.AppId,
+		ActorUserId:  user.Id,
+		TargetUserId: user.Id,
+		Metadata:     map[string]string{"method": method},
+	})
 }
 
-func stringToPointer(s string) *string {
-    if s == "" {
-        return nil 
-    }
-    return &s
+// loginFailed records a failed login. userId is 0 when the phone belongs
+// to no user, and only then is the phone recorded, as the event would
+// otherwise point nowhere.
+func (s *AuthService) loginFailed(ctx context.Context, appId int32, userId int64, phone string, method string, reason string) {
+	metadata := map[string]string{"method": method, "reason": reason}
+	if userId == 0 {
+		metadata["phone"] = phone
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:         model.AuditLoginFailed,
+		AppId:        appId,
+		TargetUserId: userId,
+		Metadata:     metadata,
+	})
+}
+
+// userError translates repository errors about users into the errors of
+// this service and returns other errors unchanged.
+func userError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrUserExists):
+		return ErrUserExists
+	default:
+		return err
+	}
+}
+
+// policyError turns a policy error into ErrWeakPassword with a violation of
+// field for every failed rule. Its metadata maps each rule to its message.
+func policyError(field string, err error) error {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return err
+	}
+
+	weak := ErrWeakPassword.WithMessage(policyErr.Error())
+	for _, v := range policyErr.Violations {
+		weak = weak.WithFields(apperr.FieldViolation{Field: field, Description: v.Message}).WithMetadata(v.Rule, v.Message)
+	}
+
+	return weak
 }
 
-func (s *AuthService) GetUser(ctx context.Context, user_id int64) (*ssov1.User, error) {
-    user, err := s.repository.GetUserById(ctx, user_id)
-    if err != nil {
-        return nil, err
-    }
-    
-    return &ssov1.User{
-        Id:          user.Id,
-        Name:        user.Name,
-        Phone:       user.Phone,
-        AppId:       user.AppId,
-        Balance:    int64(user.Balance),
-        Description: ifNilReturnEmpty(user.Description),
-        AvatarUrl:   ifNilReturnEmpty(user.AvatarUrl),
-    }, nil
+// rehashPassword replaces an outdated hash after a successful login. The swap
+// only happens while oldHash is still stored, so a password changed in the
+// meantime is never overwritten. Failures are logged; the old hash keeps
+// working and the next login tries again.
+func (s *AuthService) rehashPassword(ctx context.Context, userId int64, oldHash []byte, password string) {
+	const op = "authservice.rehashPassword"
+
+	ctx, cancel := context.WithTimeout(ctx, rehashTimeout)
+	defer cancel()
+
+	log := s.log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	newHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", slog.String("error", err.Error()))
+		return
+	}
+
+	replaced, err := s.repository.ReplacePasswordHash(ctx, userId, oldHash, newHash)
+	if err != nil {
+		log.Error("failed to store rehashed password", slog.String("error", err.Error()))
+		return
+	}
+
+	if replaced {
+		log.Info("password rehashed")
+	}
 }
 
-func ifNilReturnEmpty(s *string) string {
-    if s == nil {
-        return ""
-    }
-    return *s
+// verifyDummyHash verifies password against a hash made with the current
+// hasher settings, so that logins for unknown phones take as long as those
+// with a wrong password and do not reveal which phones are registered.
+func (s *AuthService) verifyDummyHash(password string) {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.hasher.Hash("dummy password")
+		if err != nil {
+			s.log.Error("failed to create dummy password hash", slog.String("error", err.Error()))
+			return
+		}
+		s.dummyHash = hash
+	})
+
+	if s.dummyHash != nil {
+		s.hasher.Verify(s.dummyHash, password)
+	}
 }
 
+// passwordPolicyFor applies the app's overrides to the default policy.
+func (s *AuthService) passwordPolicyFor(app *model.App) password.Policy {
+	settings := app.Settings
+	policy := s.passwordPolicy
+
+	if settings.PasswordMinLength > 0 {
+		policy.MinLength = settings.PasswordMinLength
+	}
+	if settings.PasswordMaxLength > 0 {
+		policy.MaxLength = settings.PasswordMaxLength
+	}
+
+	override(&policy.RequireUppercase, settings.PasswordRequireUppercase)
+	override(&policy.RequireLowercase, settings.PasswordRequireLowercase)
+	override(&policy.RequireDigit, settings.PasswordRequireDigit)
+	override(&policy.RequireSymbol, settings.PasswordRequireSymbol)
+	override(&policy.ForbidPersonalInfo, settings.PasswordForbidPersonalInfo)
+
+	if settings.PasswordCheckBreached != nil && !*settings.PasswordCheckBreached {
+		policy.Breached = nil
+	}
 
-func (s *AuthService) DeleteUser(ctx context.Context, user_id int64) (bool, error) {
-    return s.repository.DeleteUser(ctx, user_id)
+	return policy
 }
 
-func (s *AuthService) ChangePassword(ctx context.Context, phone string, password string, app_id int32) (string, error) {
-    const op = "authservice.ChangePassword"
-
-    var wg sync.WaitGroup
-    errChan := make(chan error, 2)
-    userChan := make(chan *model.User, 1)
-    appChan := make(chan *model.App, 1)
-
-    wg.Add(1) 
-    go func() {
-        defer wg.Done()
-        passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-        if err != nil {
-            errChan <- err
-            return
-        }
-        user, err := s.repository.UpdatePassword(ctx, phone, app_id, passHash)
-        if err != nil {
-            errChan <- err
-            return
-        }
-        userChan <- &user
-    }()
-
-    wg.Add(1)
-    go func() {
-        defer wg.Done()
-
-        app, err := s.repository.GetAppById(ctx, app_id)
-        if err != nil {
-            errChan <- err
-            return
-        }
-        appChan <- &app
-    }()
-
-    go func() {
-        wg.Wait()
-        close(errChan)
-        close(userChan)
-        close(appChan)
-    }()
-
-    var user *model.User
-    var app *model.App
-    for {
-        select {
-        case err := <-errChan:
-            if err != nil {
-                return "", fmt.Errorf("%s: %w", op, err)
-            }
-        case u := <-userChan:
-            if u != nil {
-                user = u
-            }
-        case a := <-appChan:
-            if a != nil {
-                app = a
-            }
-        case <-ctx.Done():
-            return "", fmt.Errorf("%s: context cancelled", op)
-        }
-
-        if user != nil && app != nil {
-            break
-        }
-    }
-
-    token, err := jwt.NewToken(user, app, time.Hour * 24 * 365)
-    if err != nil {
-        return "", fmt.Errorf("%s: %w", op, err)
-    }
-
-	return token, nil
+// override sets rule to the app's value, if the app has one.
+func override(rule *bool, value *bool) {
+	if value != nil {
+		*rule = *value
+	}
 }
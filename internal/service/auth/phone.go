@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+)
+
+var ErrPhoneAlreadyVerified = apperr.New(apperr.FailedPrecondition, "PHONE_ALREADY_VERIFIED", "phone is already verified")
+
+// SendPhoneVerification sends a verification code to the phone of an
+// existing user. Unknown phones are not reported and are throttled like
+// known ones, as in RequestPasswordReset.
+func (s *AuthService) SendPhoneVerification(ctx context.Context, phone string, appId int32) error {
+	const op = "authservice.SendPhoneVerification"
+
+	user, err := s.repository.GetUserByPhone(ctx, phone, appId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.log.Info("phone verification requested for unknown phone", slog.Int("app_id", int(appId)))
+			if err := s.otp.Throttle(ctx, phone, appId, model.OTPPurposePhoneVerification); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PhoneVerified() {
+		return fmt.Errorf("%s: %w", op, ErrPhoneAlreadyVerified)
+	}
+
+	if err := s.otp.Send(ctx, phone, appId, model.OTPPurposePhoneVerification); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerifyPhone checks the code, marks the phone as verified and starts a new
+// session, so that users of apps that require verification can log in right
+// after registering.
+func (s *AuthService) VerifyPhone(ctx context.Context, phone string, appId int32, code string) (model.TokenPair, error) {
+	const op = "authservice.VerifyPhone"
+
+	if err := s.otp.Verify(ctx, phone, appId, model.OTPPurposePhoneVerification, code); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repository.GetUserByPhone(ctx, phone, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	if err := s.repository.MarkPhoneVerified(ctx, user.Id); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	verified, err := s.repository.GetUserById(ctx, user.Id)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := s.issueTokens(ctx, verified, &app, "")
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// SendLoginCode sends a one-time login code to the phone of an existing user
+// of an app that allows the phone_code login method. Unknown phones are
+// throttled like known ones.
+func (s *AuthService) SendLoginCode(ctx context.Context, phone string, appId int32) error {
+	const op = "authservice.SendLoginCode"
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !app.AllowsLoginMethod(model.LoginMethodPhoneCode) {
+		return fmt.Errorf("%s: %w", op, ErrLoginMethodNotAllowed.WithMetadata("method", model.LoginMethodPhoneCode))
+	}
+
+	if _, err := s.repository.GetUserByPhone(ctx, phone, appId); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.log.Info("login code requested for unknown phone", slog.Int("app_id", int(appId)))
+			if err := s.otp.Throttle(ctx, phone, appId, model.OTPPurposeLogin); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.otp.Send(ctx, phone, appId, model.OTPPurposeLogin); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LoginWithCode starts a session for the owner of the phone. The code proves
+// ownership, so the phone is marked as verified as well.
+func (s *AuthService) LoginWithCode(ctx context.Context, phone string, appId int32, code string) (model.TokenPair, error) {
+	const op = "authservice.LoginWithCode"
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !app.AllowsLoginMethod(model.LoginMethodPhoneCode) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrLoginMethodNotAllowed.WithMetadata("method", model.LoginMethodPhoneCode))
+	}
+
+	if err := s.otp.Verify(ctx, phone, appId, model.OTPPurposeLogin, code); err != nil {
+		s.loginFailed(ctx, appId, 0, phone, model.LoginMethodPhoneCode, "invalid_code")
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.repository.GetUserByPhone(ctx, phone, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, userError(err))
+	}
+
+	if !user.PhoneVerified() {
+		if err := s.repository.MarkPhoneVerified(ctx, user.Id); err != nil {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+		now := time.Now()
+		user.PhoneVerifiedAt = &now
+	}
+
+	tokens, err := s.issueTokens(ctx, &user, &app, "")
+	if err != nil {
+		if errors.Is(err, ErrAccountSuspended) {
+			s.loginFailed(ctx, appId, user.Id, phone, model.LoginMethodPhoneCode, "suspended")
+		}
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.loggedIn(ctx, &user, model.LoginMethodPhoneCode)
+
+	return tokens, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+)
+
+var (
+	ErrInvalidToken    = apperr.New(apperr.Unauthenticated, "INVALID_TOKEN", "invalid token")
+	ErrSessionNotFound = apperr.New(apperr.NotFound, "SESSION_NOT_FOUND", "session not found")
+)
+
+func (s *AuthService) Logout(ctx context.Context, token string) error {
+	const op = "authservice.Logout"
+
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.repository.RevokeSession(ctx, claims.SessionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, token string) (int64, error) {
+	const op = "authservice.LogoutAll"
+
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := s.repository.RevokeUserSessions(ctx, claims.Uid, claims.AppId, "")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// ListSessions returns the caller's active sessions together with the id of
+// the session the token belongs to.
+func (s *AuthService) ListSessions(ctx context.Context, token string) ([]model.Session, string, error) {
+	const op = "authservice.ListSessions"
+
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := s.repository.ListActiveSessions(ctx, claims.Uid, claims.AppId)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, claims.SessionId, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, token string, sessionId string) error {
+	const op = "authservice.RevokeSession"
+
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := s.repository.GetSession(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Other users' sessions are reported as missing rather than forbidden so
+	// that session ids cannot be probed.
+	if session.UserId != claims.Uid || session.AppId != claims.AppId {
+		return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+	}
+
+	if _, err := s.repository.RevokeSession(ctx, session.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ValidateToken introspects an access token. Tokens that fail verification,
+// have expired or belong to a revoked session yield ErrInvalidToken, which
+// callers report as an inactive token rather than as a failure.
+func (s *AuthService) ValidateToken(ctx context.Context, token string) (*jwt.Claims, error) {
+	const op = "authservice.ValidateToken"
+
+	claims, err := s.authenticate(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
+
+// authenticate verifies the access token and checks that the session it was
+// issued for has not been revoked.
+func (s *AuthService) authenticate(ctx context.Context, token string) (*jwt.Claims, error) {
+	const op = "authservice.authenticate"
+
+	claims, err := jwt.ParseToken(token, func(kid string, appId int32) (*jwt.Key, error) {
+		app, err := s.apps.ActiveApp(ctx, appId)
+		if err != nil {
+			return nil, err
+		}
+		return s.keys.VerificationKey(ctx, kid, &app)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	session, err := s.repository.GetSession(ctx, claims.SessionId)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !session.Active(time.Now()) || session.UserId != claims.Uid {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return claims, nil
+}
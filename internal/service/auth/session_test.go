@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuthenticateRejectsRevokedSession(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	tokens := login(t, s, repo.addUser(1))
+
+	claims, err := s.authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+
+	if err := s.Logout(ctx, tokens.AccessToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if repo.sessions[claims.SessionId].RevokedAt == nil {
+		t.Fatal("session not revoked")
+	}
+
+	if _, err := s.authenticate(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authenticate() after logout error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestAuthenticateRejectsSessionOfOtherUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	tokens := login(t, s, repo.addUser(1))
+
+	claims, err := s.authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	repo.sessions[claims.SessionId].UserId = 2
+
+	if _, err := s.authenticate(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("authenticate() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	user := repo.addUser(1)
+	current := login(t, s, user)
+	other := login(t, s, user)
+
+	otherSession := repo.sessionOf(t, other.RefreshToken)
+	if err := s.RevokeSession(ctx, current.AccessToken, otherSession.Id); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	if _, err := s.ValidateToken(ctx, other.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken() of revoked session error = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := s.ValidateToken(ctx, current.AccessToken); err != nil {
+		t.Errorf("ValidateToken() of current session error = %v", err)
+	}
+}
+
+func TestRevokeSessionOfOtherUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	attacker := login(t, s, repo.addUser(1))
+	victim := login(t, s, repo.addUser(2))
+
+	victimSession := repo.sessionOf(t, victim.RefreshToken)
+	err := s.RevokeSession(ctx, attacker.AccessToken, victimSession.Id)
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("RevokeSession() error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if victimSession.RevokedAt != nil {
+		t.Error("another user's session was revoked")
+	}
+
+	err = s.RevokeSession(ctx, attacker.AccessToken, "missing")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() of missing session error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	user := repo.addUser(1)
+	first := login(t, s, user)
+	second := login(t, s, user)
+	bystander := login(t, s, repo.addUser(2))
+
+	revoked, err := s.LogoutAll(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatalf("LogoutAll() error = %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("LogoutAll() revoked = %d, want 2", revoked)
+	}
+
+	for _, accessToken := range []string{first.AccessToken, second.AccessToken} {
+		if _, err := s.ValidateToken(ctx, accessToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateToken() after LogoutAll error = %v, want %v", err, ErrInvalidToken)
+		}
+	}
+
+	if _, err := s.ValidateToken(ctx, bystander.AccessToken); err != nil {
+		t.Errorf("ValidateToken() of other user error = %v", err)
+	}
+}
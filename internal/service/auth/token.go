@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
+	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+	"github.com/ei-jobs/auth-service/pkg/jwk"
+)
+
+var (
+	ErrInvalidRefreshToken = apperr.New(apperr.Unauthenticated, "INVALID_REFRESH_TOKEN", "invalid refresh token")
+	ErrRefreshTokenReused  = apperr.New(apperr.Unauthenticated, "REFRESH_TOKEN_REUSED", "refresh token reused")
+	// ErrAccountSuspended carries a "suspended_until" metadata entry unless
+	// the suspension is indefinite.
+	ErrAccountSuspended = apperr.New(apperr.PermissionDenied, "ACCOUNT_SUSPENDED", "account is suspended")
+)
+
+const refreshTokenSize = 32
+
+// RefreshToken exchanges a refresh token for a new token pair. The presented
+// token is revoked on use, and presenting an already revoked token revokes
+// the session it belongs to, logging out whoever holds the newer tokens.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, appId int32) (model.TokenPair, error) {
+	const op = "authservice.RefreshToken"
+
+	log := s.log.With(slog.String("op", op))
+
+	stored, err := s.repository.GetRefreshToken(ctx, opaque.Hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stored.AppId != appId {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	if stored.RevokedAt != nil {
+		return model.TokenPair{}, s.handleRefreshTokenReuse(ctx, log, stored)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	session, err := s.repository.GetSession(ctx, stored.SessionId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !session.Active(time.Now()) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	revoked, err := s.repository.RevokeRefreshToken(ctx, stored.Id)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !revoked {
+		return model.TokenPair{}, s.handleRefreshTokenReuse(ctx, log, stored)
+	}
+
+	user, err := s.repository.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		// A deleted user's refresh tokens are simply no longer valid.
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := s.apps.ActiveApp(ctx, appId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.PhoneVerified() && !app.AllowUnverifiedLogin {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, ErrPhoneNotVerified)
+	}
+
+	tokens, err := s.issueTokens(ctx, user, &app, stored.SessionId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+func (s *AuthService) handleRefreshTokenReuse(ctx context.Context, log *slog.Logger, stored model.RefreshToken) error {
+	const op = "authservice.handleRefreshTokenReuse"
+
+	log.Warn("refresh token reuse detected, revoking session",
+		slog.Int64("user_id", stored.UserId),
+		slog.String("session_id", stored.SessionId),
+	)
+
+	if _, err := s.repository.RevokeSession(ctx, stored.SessionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return fmt.Errorf("%s: %w", op, ErrRefreshTokenReused)
+}
+
+func (s *AuthService) JWKS(ctx context.Context, appId int32) (jwk.Set, error) {
+	const op = "authservice.JWKS"
+
+	set, err := s.keys.JWKS(ctx, appId)
+	if err != nil {
+		return jwk.Set{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return set, nil
+}
+
+// issueTokens signs an access token and stores a fresh refresh token for it.
+// An empty sessionId starts a new session, otherwise the session is extended.
+// Every login and refresh goes through here, so this is where suspended
+// users are turned away.
+func (s *AuthService) issueTokens(ctx context.Context, user *model.User, app *model.App, sessionId string) (model.TokenPair, error) {
+	const op = "authservice.issueTokens"
+
+	now := time.Now()
+	if user.Suspended(now) {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, suspendedError(user))
+	}
+
+	expiresAt := now.Add(s.refreshTTLFor(app))
+
+	if sessionId == "" {
+		id, err := opaque.New(16)
+		if err != nil {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		session := &model.Session{
+			Id:        id,
+			UserId:    user.Id,
+			AppId:     user.AppId,
+			ExpiresAt: expiresAt,
+		}
+		if err := s.repository.CreateSession(ctx, session); err != nil {
+			return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+		sessionId = session.Id
+	} else if err := s.repository.TouchSession(ctx, sessionId, expiresAt); err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := s.keys.SigningKey(ctx, app)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.Roles, user.Permissions, err = s.access.UserAccess(ctx, user.Id, user.AppId)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, err := jwt.NewToken(user, app, key, sessionId, s.accessTTLFor(app), s.tokenOptions...)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refreshToken, err := opaque.New(refreshTokenSize)
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.repository.StoreRefreshToken(ctx, &model.RefreshToken{
+		TokenHash: opaque.Hash(refreshToken),
+		SessionId: sessionId,
+		UserId:    user.Id,
+		AppId:     user.AppId,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return model.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func suspendedError(user *model.User) error {
+	if user.SuspendedUntil == nil {
+		return ErrAccountSuspended
+	}
+	return ErrAccountSuspended.WithMetadata("suspended_until", user.SuspendedUntil.UTC().Format(time.RFC3339))
+}
+
+func (s *AuthService) accessTTLFor(app *model.App) time.Duration {
+	if app.Settings.AccessTTL > 0 {
+		return app.Settings.AccessTTL
+	}
+	return s.accessTTL
+}
+
+func (s *AuthService) refreshTTLFor(app *model.App) time.Duration {
+	if app.Settings.RefreshTTL > 0 {
+		return app.Settings.RefreshTTL
+	}
+	return s.refreshTTL
+}
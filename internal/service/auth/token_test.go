@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
+	"github.com/ei-jobs/auth-service/internal/lib/password"
+	repository "github.com/ei-jobs/auth-service/internal/repository/auth"
+	"github.com/ei-jobs/auth-service/pkg/jwk"
+)
+
+const testAppId = 1
+
+type fakeAuthRepository struct {
+	users    map[int64]*model.User
+	sessions map[string]*model.Session
+	tokens   map[string]*model.RefreshToken
+	nextId   int64
+
+	// loseRevokeRace makes RevokeRefreshToken report that another request
+	// revoked the token first.
+	loseRevokeRace bool
+}
+
+func newFakeAuthRepository() *fakeAuthRepository {
+	return &fakeAuthRepository{
+		users:    make(map[int64]*model.User),
+		sessions: make(map[string]*model.Session),
+		tokens:   make(map[string]*model.RefreshToken),
+	}
+}
+
+func (r *fakeAuthRepository) StoreUser(context.Context, string, string, int32, []byte) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (r *fakeAuthRepository) GetUserByPhone(_ context.Context, phone string, appId int32) (model.User, error) {
+	for _, user := range r.users {
+		if user.Phone == phone && user.AppId == appId {
+			return *user, nil
+		}
+	}
+	return model.User{}, repository.ErrUserNotFound
+}
+
+func (r *fakeAuthRepository) UpdatePassword(context.Context, string, int32, []byte, bool) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeAuthRepository) GetUserById(_ context.Context, userId int64) (*model.User, error) {
+	user, ok := r.users[userId]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeAuthRepository) StoreRefreshToken(_ context.Context, token *model.RefreshToken) error {
+	r.nextId++
+	stored := *token
+	stored.Id = r.nextId
+	r.tokens[stored.TokenHash] = &stored
+	return nil
+}
+
+func (r *fakeAuthRepository) GetRefreshToken(_ context.Context, tokenHash string) (model.RefreshToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return model.RefreshToken{}, repository.ErrRefreshTokenNotFound
+	}
+	return *token, nil
+}
+
+func (r *fakeAuthRepository) RevokeRefreshToken(_ context.Context, id int64) (bool, error) {
+	if r.loseRevokeRace {
+		return false, nil
+	}
+	for _, token := range r.tokens {
+		if token.Id == id && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAuthRepository) CreateSession(_ context.Context, session *model.Session) error {
+	stored := *session
+	r.sessions[stored.Id] = &stored
+	return nil
+}
+
+func (r *fakeAuthRepository) GetSession(_ context.Context, id string) (model.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return model.Session{}, repository.ErrSessionNotFound
+	}
+	return *session, nil
+}
+
+func (r *fakeAuthRepository) TouchSession(_ context.Context, id string, expiresAt time.Time) error {
+	if session, ok := r.sessions[id]; ok {
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (r *fakeAuthRepository) ListActiveSessions(_ context.Context, userId int64, appId int32) ([]model.Session, error) {
+	var sessions []model.Session
+	for _, session := range r.sessions {
+		if session.UserId == userId && session.AppId == appId && session.Active(time.Now()) {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeAuthRepository) RevokeSession(_ context.Context, id string) (bool, error) {
+	session, ok := r.sessions[id]
+	if !ok || session.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return true, nil
+}
+
+func (r *fakeAuthRepository) RevokeUserSessions(_ context.Context, userId int64, appId int32, exceptId string) (int64, error) {
+	var revoked int64
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserId == userId && session.AppId == appId && session.Id != exceptId && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *fakeAuthRepository) MarkPhoneVerified(context.Context, int64) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) ReplacePasswordHash(context.Context, int64, []byte, []byte) (bool, error) {
+	return false, nil
+}
+
+type fakeAppProvider struct{}
+
+func (fakeAppProvider) ActiveApp(_ context.Context, appId int32) (model.App, error) {
+	return model.App{Id: int(appId), Name: "test", Secret: "secret"}, nil
+}
+
+type fakeKeyProvider struct{}
+
+func (fakeKeyProvider) SigningKey(_ context.Context, app *model.App) (*jwt.Key, error) {
+	return jwt.NewHMACKey(app.Secret), nil
+}
+
+func (fakeKeyProvider) VerificationKey(_ context.Context, _ string, app *model.App) (*jwt.Key, error) {
+	return jwt.NewHMACKey(app.Secret), nil
+}
+
+func (fakeKeyProvider) JWKS(context.Context, int32) (jwk.Set, error) {
+	return jwk.Set{}, nil
+}
+
+type fakeAccessProvider struct{}
+
+func (fakeAccessProvider) UserAccess(context.Context, int64, int32) ([]string, []string, error) {
+	return nil, nil, nil
+}
+
+func newTestAuthService(repo *fakeAuthRepository) *AuthService {
+	return NewAuthService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, fakeAppProvider{}, fakeKeyProvider{}, nil, fakeAccessProvider{}, nil, nil, nil, password.Policy{}, 15*time.Minute, 24*time.Hour, false)
+}
+
+// addUser stores a verified user of the test app and returns it.
+func (r *fakeAuthRepository) addUser(id int64) *model.User {
+	verifiedAt := time.Now()
+	user := &model.User{Id: id, Phone: fmt.Sprintf("+770112345%02d", id), Name: "Test", AppId: testAppId, PhoneVerifiedAt: &verifiedAt}
+	r.users[id] = user
+	return user
+}
+
+// login starts a session for user the way a login does.
+func login(t *testing.T, s *AuthService, user *model.User) model.TokenPair {
+	t.Helper()
+
+	app, _ := fakeAppProvider{}.ActiveApp(context.Background(), user.AppId)
+	tokens, err := s.issueTokens(context.Background(), user, &app, "")
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+	return tokens
+}
+
+func (r *fakeAuthRepository) sessionOf(t *testing.T, refreshToken string) *model.Session {
+	t.Helper()
+
+	token, ok := r.tokens[opaque.Hash(refreshToken)]
+	if !ok {
+		t.Fatal("refresh token not stored")
+	}
+	return r.sessions[token.SessionId]
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	first := login(t, s, repo.addUser(1))
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken, testAppId)
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token not rotated")
+	}
+	if repo.tokens[opaque.Hash(first.RefreshToken)].RevokedAt == nil {
+		t.Error("used refresh token not revoked")
+	}
+	if repo.sessionOf(t, first.RefreshToken).Id != repo.sessionOf(t, second.RefreshToken).Id {
+		t.Error("refresh started a new session")
+	}
+
+	if _, err := s.RefreshToken(ctx, second.RefreshToken, testAppId); err != nil {
+		t.Errorf("RefreshToken() with rotated token error = %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	first := login(t, s, repo.addUser(1))
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken, testAppId)
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+
+	_, err = s.RefreshToken(ctx, first.RefreshToken, testAppId)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() reuse error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if repo.sessionOf(t, first.RefreshToken).RevokedAt == nil {
+		t.Fatal("session not revoked after reuse")
+	}
+
+	// Whoever holds the newer token is logged out along with the reuser.
+	_, err = s.RefreshToken(ctx, second.RefreshToken, testAppId)
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken() with newer token error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := s.ValidateToken(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRefreshTokenLostRevokeRaceIsReuse(t *testing.T) {
+	repo := newFakeAuthRepository()
+	s := newTestAuthService(repo)
+	tokens := login(t, s, repo.addUser(1))
+
+	// The token still looks unused when it is read, but a concurrent refresh
+	// revokes it first.
+	repo.loseRevokeRace = true
+
+	_, err := s.RefreshToken(context.Background(), tokens.RefreshToken, testAppId)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if repo.sessionOf(t, tokens.RefreshToken).RevokedAt == nil {
+		t.Error("session not revoked after lost race")
+	}
+}
+
+func TestRefreshTokenRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		appId int32
+		setup func(repo *fakeAuthRepository, token *model.RefreshToken)
+	}{
+		{
+			name:  "other app",
+			appId: testAppId + 1,
+		},
+		{
+			name:  "expired",
+			appId: testAppId,
+			setup: func(_ *fakeAuthRepository, token *model.RefreshToken) {
+				token.ExpiresAt = time.Now().Add(-time.Second)
+			},
+		},
+		{
+			name:  "deleted user",
+			appId: testAppId,
+			setup: func(repo *fakeAuthRepository, token *model.RefreshToken) {
+				delete(repo.users, token.UserId)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeAuthRepository()
+			s := newTestAuthService(repo)
+			tokens := login(t, s, repo.addUser(1))
+
+			stored := repo.tokens[opaque.Hash(tokens.RefreshToken)]
+			if tt.setup != nil {
+				tt.setup(repo, stored)
+			}
+
+			_, err := s.RefreshToken(context.Background(), tokens.RefreshToken, tt.appId)
+			if !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("RefreshToken() error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+			if repo.sessionOf(t, tokens.RefreshToken).RevokedAt != nil {
+				t.Error("session revoked for a rejected token")
+			}
+		})
+	}
+}
+
+func TestRefreshTokenUnknown(t *testing.T) {
+	s := newTestAuthService(newFakeAuthRepository())
+
+	_, err := s.RefreshToken(context.Background(), "unknown", testAppId)
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken() error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
+	repository "github.com/ei-jobs/auth-service/internal/repository/keys"
+	"github.com/ei-jobs/auth-service/pkg/jwk"
+)
+
+var ErrKeyNotFound = apperr.New(apperr.NotFound, "SIGNING_KEY_NOT_FOUND", "signing key not found")
+
+const rotationCheckInterval = 10 * time.Minute
+
+type KeyRepository interface {
+	StoreKey(ctx context.Context, key *model.SigningKey) error
+	GetKeyByKid(ctx context.Context, kid string) (model.SigningKey, error)
+	GetActiveKey(ctx context.Context, appId *int32, algorithm string) (model.SigningKey, error)
+	ListActiveKeys(ctx context.Context) ([]model.SigningKey, error)
+	ListPublishedKeys(ctx context.Context, appId int32) ([]model.SigningKey, error)
+	RetireKey(ctx context.Context, id int64, retiredAt time.Time, expiresAt time.Time) error
+	ReplacePrivateKey(ctx context.Context, id int64, currentKey string, privateKey string) (bool, error)
+}
+
+// SecretBox encrypts private keys at rest. Open returns values that were
+// never sealed unchanged.
+type SecretBox interface {
+	Seal(plaintext string) (string, error)
+	Open(value string) (string, error)
+	IsSealed(value string) bool
+}
+
+// KeyService owns the token signing keys. With the HS256 algorithm it falls
+// back to the per-app secret; otherwise it signs with asymmetric keys that are
+// created on first use, rotated every rotationInterval and kept published for
+// retention after they are retired. Private keys are stored sealed; keys
+// stored in plaintext are sealed when they are first loaded.
+type KeyService struct {
+	log              *slog.Logger
+	repository       KeyRepository
+	secrets          SecretBox
+	algorithm        string
+	perApp           bool
+	rotationInterval time.Duration
+	retention        time.Duration
+
+	mu     sync.RWMutex
+	parsed map[string]*jwt.Key
+}
+
+func NewKeyService(log *slog.Logger, repository KeyRepository, secrets SecretBox, algorithm string, perApp bool, rotationInterval time.Duration, retention time.Duration) *KeyService {
+	return &KeyService{
+		log:              log,
+		repository:       repository,
+		secrets:          secrets,
+		algorithm:        algorithm,
+		perApp:           perApp,
+		rotationInterval: rotationInterval,
+		retention:        retention,
+		parsed:           make(map[string]*jwt.Key),
+	}
+}
+
+func (s *KeyService) SigningKey(ctx context.Context, app *model.App) (*jwt.Key, error) {
+	const op = "keyservice.SigningKey"
+
+	if s.algorithm == jwt.AlgHS256 {
+		return jwt.NewHMACKey(app.Secret), nil
+	}
+
+	stored, err := s.repository.GetActiveKey(ctx, s.scope(app), s.algorithm)
+	if errors.Is(err, repository.ErrKeyNotFound) {
+		stored, err = s.createKey(ctx, s.scope(app))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := s.parse(ctx, stored)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// VerificationKey resolves the key a token of app was signed with. Tokens
+// without a kid are only accepted while the service signs with HS256, and
+// verify with the app secret or, after a rotation, the previous one.
+func (s *KeyService) VerificationKey(ctx context.Context, kid string, app *model.App) (*jwt.Key, error) {
+	const op = "keyservice.VerificationKey"
+
+	if kid == "" {
+		if s.algorithm != jwt.AlgHS256 {
+			return nil, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+		if app.PreviousSecretValid(time.Now()) {
+			return jwt.NewHMACKey(app.Secret, *app.PreviousSecret), nil
+		}
+		return jwt.NewHMACKey(app.Secret), nil
+	}
+
+	stored, err := s.repository.GetKeyByKid(ctx, kid)
+	if err != nil {
+		if errors.Is(err, repository.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stored.AppId != nil && int(*stored.AppId) != app.Id {
+		return nil, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+	}
+
+	if stored.ExpiresAt != nil && time.Now().After(*stored.ExpiresAt) {
+		return nil, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+	}
+
+	key, err := s.parse(ctx, stored)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// JWKS returns the public keys that verify tokens of appId: the global keys
+// plus the keys of that app.
+func (s *KeyService) JWKS(ctx context.Context, appId int32) (jwk.Set, error) {
+	const op = "keyservice.JWKS"
+
+	stored, err := s.repository.ListPublishedKeys(ctx, appId)
+	if err != nil {
+		return jwk.Set{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	set := jwk.Set{Keys: make([]jwk.Key, 0, len(stored))}
+	for _, k := range stored {
+		key, err := s.parse(ctx, k)
+		if err != nil {
+			return jwk.Set{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		published, err := jwk.FromPublicKey(k.Kid, k.Algorithm, key.VerifyKey)
+		if err != nil {
+			return jwk.Set{}, fmt.Errorf("%s: %w", op, err)
+		}
+		set.Keys = append(set.Keys, published)
+	}
+
+	return set, nil
+}
+
+// Rotate retires every active key that is older than the rotation interval,
+// uses an outdated algorithm or has been superseded by a newer key of the
+// same scope. A replacement is created before a key is retired.
+func (s *KeyService) Rotate(ctx context.Context) error {
+	const op = "keyservice.Rotate"
+
+	if s.algorithm == jwt.AlgHS256 {
+		return nil
+	}
+
+	active, err := s.repository.ListActiveKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	newest := make(map[int32]bool)
+
+	// active is ordered newest first, so the first current key of every scope
+	// is the one that keeps signing.
+	for _, key := range active {
+		scope := scopeKey(key.AppId)
+		fresh := key.Algorithm == s.algorithm && now.Sub(key.CreatedAt) < s.rotationInterval
+
+		if fresh && !newest[scope] {
+			newest[scope] = true
+			continue
+		}
+
+		if !newest[scope] {
+			if _, err := s.createKey(ctx, key.AppId); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			newest[scope] = true
+		}
+
+		if err := s.repository.RetireKey(ctx, key.Id, now, now.Add(s.retention)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		s.log.Info("signing key retired", slog.String("kid", key.Kid))
+	}
+
+	return nil
+}
+
+// RunRotation checks for keys due for rotation until ctx is cancelled.
+func (s *KeyService) RunRotation(ctx context.Context) {
+	const op = "keyservice.RunRotation"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Rotate(ctx); err != nil {
+			log.Error("failed to rotate signing keys", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *KeyService) createKey(ctx context.Context, appId *int32) (model.SigningKey, error) {
+	privatePEM, publicPEM, err := jwt.GenerateKeyPair(s.algorithm)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	kid, err := opaque.New(16)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	sealed, err := s.secrets.Seal(privatePEM)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	key := model.SigningKey{
+		Kid:        kid,
+		AppId:      appId,
+		Algorithm:  s.algorithm,
+		PrivateKey: sealed,
+		PublicKey:  publicPEM,
+	}
+	if err := s.repository.StoreKey(ctx, &key); err != nil {
+		return model.SigningKey{}, err
+	}
+
+	s.log.Info("signing key created", slog.String("kid", kid), slog.String("algorithm", s.algorithm))
+
+	return key, nil
+}
+
+func (s *KeyService) parse(ctx context.Context, stored model.SigningKey) (*jwt.Key, error) {
+	s.mu.RLock()
+	key, ok := s.parsed[stored.Kid]
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	privatePEM, err := s.secrets.Open(stored.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = jwt.ParsePrivateKey(stored.Kid, stored.Algorithm, privatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.secrets.IsSealed(stored.PrivateKey) {
+		s.encryptPrivateKey(ctx, stored, privatePEM)
+	}
+
+	s.mu.Lock()
+	s.parsed[stored.Kid] = key
+	s.mu.Unlock()
+
+	return key, nil
+}
+
+// encryptPrivateKey stores a plaintext private key sealed. Failures are only
+// logged: the key is usable either way and the next load retries.
+func (s *KeyService) encryptPrivateKey(ctx context.Context, stored model.SigningKey, privatePEM string) {
+	const op = "keyservice.encryptPrivateKey"
+
+	log := s.log.With(slog.String("op", op), slog.String("kid", stored.Kid))
+
+	sealed, err := s.secrets.Seal(privatePEM)
+	if err != nil {
+		log.Error("failed to encrypt signing key", slog.String("error", err.Error()))
+		return
+	}
+
+	replaced, err := s.repository.ReplacePrivateKey(ctx, stored.Id, stored.PrivateKey, sealed)
+	if err != nil {
+		log.Error("failed to store encrypted signing key", slog.String("error", err.Error()))
+		return
+	}
+
+	if replaced {
+		log.Info("encrypted plaintext signing key")
+	}
+}
+
+func (s *KeyService) scope(app *model.App) *int32 {
+	if !s.perApp {
+		return nil
+	}
+
+	appId := int32(app.Id)
+
+	return &appId
+}
+
+func scopeKey(appId *int32) int32 {
+	if appId == nil {
+		return 0
+	}
+
+	return *appId
+}
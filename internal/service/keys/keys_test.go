@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/jwt"
+	"github.com/ei-jobs/auth-service/internal/lib/secretbox"
+	repository "github.com/ei-jobs/auth-service/internal/repository/keys"
+)
+
+const (
+	testRotationInterval = 24 * time.Hour
+	testRetention        = 48 * time.Hour
+)
+
+type fakeKeyRepository struct {
+	keys   []*model.SigningKey
+	nextId int64
+	// events records stores and retirements in the order they happen.
+	events []string
+}
+
+func (r *fakeKeyRepository) StoreKey(_ context.Context, key *model.SigningKey) error {
+	r.nextId++
+	key.Id = r.nextId
+	key.CreatedAt = time.Now()
+	stored := *key
+	r.keys = append(r.keys, &stored)
+	r.events = append(r.events, "store")
+	return nil
+}
+
+func (r *fakeKeyRepository) GetKeyByKid(_ context.Context, kid string) (model.SigningKey, error) {
+	for _, key := range r.keys {
+		if key.Kid == kid {
+			return *key, nil
+		}
+	}
+	return model.SigningKey{}, repository.ErrKeyNotFound
+}
+
+func (r *fakeKeyRepository) GetActiveKey(_ context.Context, appId *int32, algorithm string) (model.SigningKey, error) {
+	active, _ := r.ListActiveKeys(context.Background())
+	for _, key := range active {
+		if scopeKey(key.AppId) == scopeKey(appId) && key.Algorithm == algorithm {
+			return key, nil
+		}
+	}
+	return model.SigningKey{}, repository.ErrKeyNotFound
+}
+
+func (r *fakeKeyRepository) ListActiveKeys(context.Context) ([]model.SigningKey, error) {
+	var active []model.SigningKey
+	for _, key := range r.keys {
+		if key.RetiredAt == nil {
+			active = append(active, *key)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].CreatedAt.After(active[j].CreatedAt)
+	})
+	return active, nil
+}
+
+func (r *fakeKeyRepository) ListPublishedKeys(context.Context, int32) ([]model.SigningKey, error) {
+	return nil, nil
+}
+
+func (r *fakeKeyRepository) RetireKey(_ context.Context, id int64, retiredAt time.Time, expiresAt time.Time) error {
+	for _, key := range r.keys {
+		if key.Id == id {
+			key.RetiredAt = &retiredAt
+			key.ExpiresAt = &expiresAt
+		}
+	}
+	r.events = append(r.events, "retire")
+	return nil
+}
+
+func (r *fakeKeyRepository) ReplacePrivateKey(_ context.Context, id int64, currentKey string, privateKey string) (bool, error) {
+	for _, key := range r.keys {
+		if key.Id == id && key.PrivateKey == currentKey {
+			key.PrivateKey = privateKey
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// addKey stores a key of appId created age ago. The private key is sealed
+// with box unless box is nil.
+func (r *fakeKeyRepository) addKey(t *testing.T, box *secretbox.Box, kid string, appId *int32, algorithm string, age time.Duration) *model.SigningKey {
+	t.Helper()
+
+	privatePEM, publicPEM, err := jwt.GenerateKeyPair(algorithm)
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+
+	if box != nil {
+		privatePEM, err = box.Seal(privatePEM)
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+	}
+
+	r.nextId++
+	key := &model.SigningKey{
+		Id:         r.nextId,
+		Kid:        kid,
+		AppId:      appId,
+		Algorithm:  algorithm,
+		PrivateKey: privatePEM,
+		PublicKey:  publicPEM,
+		CreatedAt:  time.Now().Add(-age),
+	}
+	r.keys = append(r.keys, key)
+
+	return key
+}
+
+func newTestBox(t *testing.T) *secretbox.Box {
+	t.Helper()
+
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatalf("secretbox.New() error = %v", err)
+	}
+
+	return box
+}
+
+func newTestKeyService(repo KeyRepository, box SecretBox, algorithm string, perApp bool) *KeyService {
+	return NewKeyService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, box, algorithm, perApp, testRotationInterval, testRetention)
+}
+
+func appScope(id int32) *int32 {
+	return &id
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box)
+		wantRetired []string
+		wantCreated int
+	}{
+		{
+			name: "fresh key kept",
+			setup: func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box) {
+				repo.addKey(t, box, "fresh", nil, jwt.AlgES256, time.Hour)
+			},
+		},
+		{
+			name: "expired interval",
+			setup: func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box) {
+				repo.addKey(t, box, "stale", nil, jwt.AlgES256, testRotationInterval+time.Hour)
+			},
+			wantRetired: []string{"stale"},
+			wantCreated: 1,
+		},
+		{
+			name: "outdated algorithm",
+			setup: func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box) {
+				repo.addKey(t, box, "rsa", nil, jwt.AlgRS256, time.Hour)
+			},
+			wantRetired: []string{"rsa"},
+			wantCreated: 1,
+		},
+		{
+			name: "superseded keys of a scope",
+			setup: func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box) {
+				repo.addKey(t, box, "older", nil, jwt.AlgES256, 2*time.Hour)
+				repo.addKey(t, box, "oldest", nil, jwt.AlgES256, 3*time.Hour)
+				repo.addKey(t, box, "newest", nil, jwt.AlgES256, time.Hour)
+			},
+			wantRetired: []string{"older", "oldest"},
+		},
+		{
+			name: "scopes rotate independently",
+			setup: func(t *testing.T, repo *fakeKeyRepository, box *secretbox.Box) {
+				repo.addKey(t, box, "app1", appScope(1), jwt.AlgES256, time.Hour)
+				repo.addKey(t, box, "app2", appScope(2), jwt.AlgES256, testRotationInterval+time.Hour)
+			},
+			wantRetired: []string{"app2"},
+			wantCreated: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeKeyRepository{}
+			box := newTestBox(t)
+			tt.setup(t, repo, box)
+			before := len(repo.keys)
+			s := newTestKeyService(repo, box, jwt.AlgES256, true)
+
+			if err := s.Rotate(context.Background()); err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+
+			var retired []string
+			for _, key := range repo.keys {
+				if key.RetiredAt != nil {
+					retired = append(retired, key.Kid)
+					if want := key.RetiredAt.Add(testRetention); !key.ExpiresAt.Equal(want) {
+						t.Errorf("key %s expires at %v, want %v", key.Kid, key.ExpiresAt, want)
+					}
+				}
+			}
+			sort.Strings(retired)
+			if strings.Join(retired, ",") != strings.Join(tt.wantRetired, ",") {
+				t.Errorf("retired = %v, want %v", retired, tt.wantRetired)
+			}
+
+			if created := len(repo.keys) - before; created != tt.wantCreated {
+				t.Errorf("created %d keys, want %d", created, tt.wantCreated)
+			}
+
+			// Every scope keeps exactly one key to sign with.
+			active, _ := repo.ListActiveKeys(context.Background())
+			scopes := make(map[int32]int)
+			for _, key := range active {
+				scopes[scopeKey(key.AppId)]++
+			}
+			for scope, n := range scopes {
+				if n != 1 {
+					t.Errorf("scope %d has %d active keys, want 1", scope, n)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateCreatesReplacementBeforeRetiring(t *testing.T) {
+	repo := &fakeKeyRepository{}
+	box := newTestBox(t)
+	repo.addKey(t, box, "stale", nil, jwt.AlgES256, testRotationInterval+time.Hour)
+	s := newTestKeyService(repo, box, jwt.AlgES256, false)
+
+	if err := s.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if got := strings.Join(repo.events, ","); got != "store,retire" {
+		t.Errorf("events = %s, want store,retire", got)
+	}
+}
+
+func TestRotateHS256(t *testing.T) {
+	repo := &fakeKeyRepository{}
+	box := newTestBox(t)
+	repo.addKey(t, box, "stale", nil, jwt.AlgES256, testRotationInterval+time.Hour)
+	s := newTestKeyService(repo, box, jwt.AlgHS256, false)
+
+	if err := s.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if len(repo.events) != 0 {
+		t.Errorf("events = %v, want none", repo.events)
+	}
+}
+
+func TestVerificationKey(t *testing.T) {
+	repo := &fakeKeyRepository{}
+	box := newTestBox(t)
+	repo.addKey(t, box, "global", nil, jwt.AlgES256, time.Hour)
+	repo.addKey(t, box, "own", appScope(1), jwt.AlgES256, time.Hour)
+	repo.addKey(t, box, "other", appScope(2), jwt.AlgES256, time.Hour)
+
+	expired := repo.addKey(t, box, "expired", appScope(1), jwt.AlgES256, 3*testRotationInterval)
+	retiredAt := time.Now().Add(-2 * testRetention)
+	expiresAt := time.Now().Add(-time.Minute)
+	expired.RetiredAt, expired.ExpiresAt = &retiredAt, &expiresAt
+
+	retired := repo.addKey(t, box, "retired", appScope(1), jwt.AlgES256, 2*testRotationInterval)
+	recentlyRetiredAt, graceEndsAt := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	retired.RetiredAt, retired.ExpiresAt = &recentlyRetiredAt, &graceEndsAt
+
+	tests := []struct {
+		name    string
+		kid     string
+		wantErr error
+	}{
+		{name: "own app key", kid: "own"},
+		{name: "global key", kid: "global"},
+		{name: "retired key within retention", kid: "retired"},
+		{name: "no kid", kid: "", wantErr: ErrKeyNotFound},
+		{name: "unknown kid", kid: "unknown", wantErr: ErrKeyNotFound},
+		{name: "other app key", kid: "other", wantErr: ErrKeyNotFound},
+		{name: "past expires_at", kid: "expired", wantErr: ErrKeyNotFound},
+	}
+
+	s := newTestKeyService(repo, box, jwt.AlgES256, true)
+	app := &model.App{Id: 1, Secret: "secret"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := s.VerificationKey(context.Background(), tt.kid, app)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerificationKey() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerificationKey() error = %v", err)
+			}
+			if key.Id != tt.kid {
+				t.Errorf("VerificationKey() kid = %q, want %q", key.Id, tt.kid)
+			}
+		})
+	}
+}
+
+func TestVerificationKeyHS256WithoutKid(t *testing.T) {
+	s := newTestKeyService(&fakeKeyRepository{}, newTestBox(t), jwt.AlgHS256, false)
+
+	key, err := s.VerificationKey(context.Background(), "", &model.App{Id: 1, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("VerificationKey() error = %v", err)
+	}
+	if key.Method.Alg() != jwt.AlgHS256 {
+		t.Errorf("VerificationKey() alg = %s, want %s", key.Method.Alg(), jwt.AlgHS256)
+	}
+}
+
+func TestPlaintextKeyIsSealedOnLoad(t *testing.T) {
+	repo := &fakeKeyRepository{}
+	box := newTestBox(t)
+	stored := repo.addKey(t, nil, "plain", nil, jwt.AlgES256, time.Hour)
+	privatePEM := stored.PrivateKey
+	s := newTestKeyService(repo, box, jwt.AlgES256, false)
+
+	if _, err := s.VerificationKey(context.Background(), "plain", &model.App{Id: 1}); err != nil {
+		t.Fatalf("VerificationKey() error = %v", err)
+	}
+
+	if !box.IsSealed(stored.PrivateKey) {
+		t.Fatal("private key still stored in plaintext")
+	}
+	opened, err := box.Open(stored.PrivateKey)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if opened != privatePEM {
+		t.Error("sealed private key does not open to the original")
+	}
+}
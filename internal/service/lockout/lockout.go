@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+type LockoutRepository interface {
+	GetThrottle(ctx context.Context, scope string, key string) (model.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope string, key string, now time.Time, windowStart time.Time) (model.LoginThrottle, error)
+	Lock(ctx context.Context, scope string, key string, until time.Time) error
+	Reset(ctx context.Context, scope string, key string) (bool, error)
+	DeleteStale(ctx context.Context, windowStart time.Time, now time.Time) (int64, error)
+}
+
+// Both errors are returned with RetryAfter set to how long the caller has
+// to wait.
+var (
+	// ErrThrottled means the caller must wait out the backoff delay of its
+	// previous failures before trying again.
+	ErrThrottled = apperr.New(apperr.ResourceExhausted, "LOGIN_THROTTLED", "too many failed attempts, try again later")
+	// ErrLocked means the account or IP is locked after reaching the
+	// failure threshold.
+	ErrLocked = apperr.New(apperr.PermissionDenied, "ACCOUNT_LOCKED", "account is temporarily locked")
+)
+
+const cleanupInterval = time.Hour
+
+// Policy configures the lockout. DisableIPLockout stops counting failures
+// per client IP, for deployments that cannot tell client addresses apart,
+// such as behind a proxy that is not trusted to forward them.
+type Policy struct {
+	MaxAttempts      int
+	IPMaxAttempts    int
+	DisableIPLockout bool
+	Window           time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockDuration     time.Duration
+}
+
+// LockoutService slows down password guessing. Failed logins are counted
+// per account, keyed by app and phone, and per client IP. Every failure
+// delays the next attempt exponentially, and reaching the threshold locks
+// the key for LockDuration.
+type LockoutService struct {
+	log        *slog.Logger
+	repository LockoutRepository
+	policy     Policy
+}
+
+func NewLockoutService(log *slog.Logger, repository LockoutRepository, policy Policy) *LockoutService {
+	return &LockoutService{
+		log:        log,
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+// Check returns ErrLocked or ErrThrottled, with RetryAfter set, if the
+// account or the IP may not attempt a login yet. An empty ip is not checked,
+// and neither is any ip while the IP lockout is disabled.
+func (s *LockoutService) Check(ctx context.Context, phone string, appId int32, ip string) error {
+	const op = "lockoutservice.Check"
+
+	now := time.Now()
+
+	for _, k := range s.keys(phone, appId, ip) {
+		throttle, err := s.repository.GetThrottle(ctx, k.scope, k.key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := s.check(throttle, now); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the keys that reached
+// their threshold.
+func (s *LockoutService) RecordFailure(ctx context.Context, phone string, appId int32, ip string) error {
+	const op = "lockoutservice.RecordFailure"
+
+	now := time.Now()
+
+	for _, k := range s.keys(phone, appId, ip) {
+		throttle, err := s.repository.RecordFailure(ctx, k.scope, k.key, now, now.Add(-s.policy.Window))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if throttle.Failures < s.threshold(k.scope) {
+			continue
+		}
+
+		if err := s.repository.Lock(ctx, k.scope, k.key, now.Add(s.policy.LockDuration)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		s.log.Warn("login locked after repeated failures",
+			slog.String("scope", k.scope),
+			slog.Int("app_id", int(appId)),
+			slog.Int("failures", throttle.Failures),
+		)
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the failures of the account. The IP keeps its count,
+// since one success says nothing about other accounts tried from it.
+func (s *LockoutService) RecordSuccess(ctx context.Context, phone string, appId int32) error {
+	const op = "lockoutservice.RecordSuccess"
+
+	if _, err := s.repository.Reset(ctx, model.ThrottleScopeAccount, accountKey(phone, appId)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Unlock lifts a lock on the account and clears its failures. It reports
+// whether there was anything to clear.
+func (s *LockoutService) Unlock(ctx context.Context, phone string, appId int32) (bool, error) {
+	const op = "lockoutservice.Unlock"
+
+	unlocked, err := s.repository.Reset(ctx, model.ThrottleScopeAccount, accountKey(phone, appId))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if unlocked {
+		s.log.Info("account unlocked", slog.Int("app_id", int(appId)))
+	}
+
+	return unlocked, nil
+}
+
+// RunCleanup periodically deletes counters that have expired.
+func (s *LockoutService) RunCleanup(ctx context.Context) {
+	const op = "lockoutservice.RunCleanup"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if _, err := s.repository.DeleteStale(ctx, now.Add(-s.policy.Window), now); err != nil {
+			log.Error("failed to delete stale login throttles", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *LockoutService) check(throttle model.LoginThrottle, now time.Time) error {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return ErrLocked.WithRetryAfter(throttle.LockedUntil.Sub(now))
+	}
+
+	if throttle.Failures == 0 || throttle.LastFailureAt.Before(now.Add(-s.policy.Window)) {
+		return nil
+	}
+
+	next := throttle.LastFailureAt.Add(s.delay(throttle.Failures))
+	if now.Before(next) {
+		return ErrThrottled.WithRetryAfter(next.Sub(now))
+	}
+
+	return nil
+}
+
+// delay is BaseDelay doubled for every failure after the first, capped at
+// MaxDelay.
+func (s *LockoutService) delay(failures int) time.Duration {
+	delay := s.policy.BaseDelay
+	for i := 1; i < failures && delay < s.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > s.policy.MaxDelay {
+		return s.policy.MaxDelay
+	}
+
+	return delay
+}
+
+func (s *LockoutService) threshold(scope string) int {
+	if scope == model.ThrottleScopeIP {
+		return s.policy.IPMaxAttempts
+	}
+	return s.policy.MaxAttempts
+}
+
+type throttleKey struct {
+	scope string
+	key   string
+}
+
+func (s *LockoutService) keys(phone string, appId int32, ip string) []throttleKey {
+	k := []throttleKey{{scope: model.ThrottleScopeAccount, key: accountKey(phone, appId)}}
+	if ip != "" && !s.policy.DisableIPLockout {
+		k = append(k, throttleKey{scope: model.ThrottleScopeIP, key: ip})
+	}
+	return k
+}
+
+func accountKey(phone string, appId int32) string {
+	return strconv.Itoa(int(appId)) + ":" + phone
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+)
+
+var testPolicy = Policy{
+	MaxAttempts:   5,
+	IPMaxAttempts: 20,
+	Window:        15 * time.Minute,
+	BaseDelay:     time.Second,
+	MaxDelay:      30 * time.Second,
+	LockDuration:  time.Hour,
+}
+
+func newTestService(repository LockoutRepository) *LockoutService {
+	return NewLockoutService(slog.New(slog.NewTextHandler(io.Discard, nil)), repository, testPolicy)
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 3, want: 4 * time.Second},
+		{failures: 5, want: 16 * time.Second},
+		{failures: 6, want: 30 * time.Second},
+		{failures: 100, want: 30 * time.Second},
+	}
+
+	s := newTestService(nil)
+	for _, tt := range tests {
+		if got := s.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestCheckThrottle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	lockedUntil := now.Add(10 * time.Minute)
+	expiredLock := now.Add(-time.Minute)
+
+	tests := []struct {
+		name           string
+		throttle       model.LoginThrottle
+		wantErr        error
+		wantRetryAfter time.Duration
+	}{
+		{
+			name: "no failures",
+		},
+		{
+			name:           "locked",
+			throttle:       model.LoginThrottle{Failures: 5, LastFailureAt: now, LockedUntil: &lockedUntil},
+			wantErr:        ErrLocked,
+			wantRetryAfter: 10 * time.Minute,
+		},
+		{
+			name:     "lock expired and delay passed",
+			throttle: model.LoginThrottle{Failures: 1, LastFailureAt: now.Add(-2 * time.Second), LockedUntil: &expiredLock},
+		},
+		{
+			name:           "within delay of one failure",
+			throttle:       model.LoginThrottle{Failures: 1, LastFailureAt: now.Add(-200 * time.Millisecond)},
+			wantErr:        ErrThrottled,
+			wantRetryAfter: 800 * time.Millisecond,
+		},
+		{
+			name:           "within delay of three failures",
+			throttle:       model.LoginThrottle{Failures: 3, LastFailureAt: now.Add(-time.Second)},
+			wantErr:        ErrThrottled,
+			wantRetryAfter: 3 * time.Second,
+		},
+		{
+			name:     "delay passed",
+			throttle: model.LoginThrottle{Failures: 3, LastFailureAt: now.Add(-4 * time.Second)},
+		},
+		{
+			name:     "failures outside window",
+			throttle: model.LoginThrottle{Failures: 4, LastFailureAt: now.Add(-16 * time.Minute)},
+		},
+	}
+
+	s := newTestService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.check(tt.throttle, now)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("check() error = %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("check() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) {
+				t.Fatalf("check() error = %T, want *apperr.Error", err)
+			}
+			if appErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("RetryAfter = %v, want %v", appErr.RetryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+type fakeLockoutRepository struct {
+	throttles map[throttleKey]model.LoginThrottle
+}
+
+func newFakeLockoutRepository() *fakeLockoutRepository {
+	return &fakeLockoutRepository{throttles: make(map[throttleKey]model.LoginThrottle)}
+}
+
+func (r *fakeLockoutRepository) GetThrottle(_ context.Context, scope string, key string) (model.LoginThrottle, error) {
+	return r.throttles[throttleKey{scope: scope, key: key}], nil
+}
+
+func (r *fakeLockoutRepository) RecordFailure(_ context.Context, scope string, key string, now time.Time, windowStart time.Time) (model.LoginThrottle, error) {
+	k := throttleKey{scope: scope, key: key}
+	throttle := r.throttles[k]
+	if throttle.LastFailureAt.Before(windowStart) {
+		throttle.Failures = 0
+	}
+	throttle.Scope, throttle.Key = scope, key
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	r.throttles[k] = throttle
+	return throttle, nil
+}
+
+func (r *fakeLockoutRepository) Lock(_ context.Context, scope string, key string, until time.Time) error {
+	k := throttleKey{scope: scope, key: key}
+	throttle := r.throttles[k]
+	throttle.LockedUntil = &until
+	r.throttles[k] = throttle
+	return nil
+}
+
+func (r *fakeLockoutRepository) Reset(_ context.Context, scope string, key string) (bool, error) {
+	k := throttleKey{scope: scope, key: key}
+	_, ok := r.throttles[k]
+	delete(r.throttles, k)
+	return ok, nil
+}
+
+func (r *fakeLockoutRepository) DeleteStale(context.Context, time.Time, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestRecordFailureLocks(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		failures    int
+		wantAccount bool
+	}{
+		{name: "below threshold", failures: testPolicy.MaxAttempts - 1},
+		{name: "at threshold", failures: testPolicy.MaxAttempts, wantAccount: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeLockoutRepository()
+			s := newTestService(repo)
+
+			for i := 0; i < tt.failures; i++ {
+				if err := s.RecordFailure(ctx, "+77011234567", 1, "10.0.0.1"); err != nil {
+					t.Fatalf("RecordFailure() error = %v", err)
+				}
+			}
+
+			account := repo.throttles[throttleKey{scope: model.ThrottleScopeAccount, key: accountKey("+77011234567", 1)}]
+			if got := account.LockedUntil != nil; got != tt.wantAccount {
+				t.Errorf("account locked = %v, want %v", got, tt.wantAccount)
+			}
+
+			ip := repo.throttles[throttleKey{scope: model.ThrottleScopeIP, key: "10.0.0.1"}]
+			if ip.LockedUntil != nil {
+				t.Error("ip locked below its threshold")
+			}
+
+			err := s.Check(ctx, "+77011234567", 1, "10.0.0.1")
+			if tt.wantAccount && !errors.Is(err, ErrLocked) {
+				t.Errorf("Check() error = %v, want %v", err, ErrLocked)
+			}
+			if !tt.wantAccount && !errors.Is(err, ErrThrottled) {
+				t.Errorf("Check() error = %v, want %v", err, ErrThrottled)
+			}
+		})
+	}
+}
+
+func TestRecordSuccessKeepsIPFailures(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeLockoutRepository()
+	s := newTestService(repo)
+
+	if err := s.RecordFailure(ctx, "+77011234567", 1, "10.0.0.1"); err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	if err := s.RecordSuccess(ctx, "+77011234567", 1); err != nil {
+		t.Fatalf("RecordSuccess() error = %v", err)
+	}
+
+	if _, ok := repo.throttles[throttleKey{scope: model.ThrottleScopeAccount, key: accountKey("+77011234567", 1)}]; ok {
+		t.Error("account failures not cleared")
+	}
+	if ip := repo.throttles[throttleKey{scope: model.ThrottleScopeIP, key: "10.0.0.1"}]; ip.Failures != 1 {
+		t.Errorf("ip failures = %d, want 1", ip.Failures)
+	}
+}
+
+func TestDisableIPLockout(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeLockoutRepository()
+	policy := testPolicy
+	policy.DisableIPLockout = true
+	s := NewLockoutService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, policy)
+
+	for i := 0; i < policy.IPMaxAttempts; i++ {
+		if err := s.RecordFailure(ctx, fmt.Sprintf("+770112345%02d", i), 1, "10.0.0.1"); err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+	}
+
+	if _, ok := repo.throttles[throttleKey{scope: model.ThrottleScopeIP, key: "10.0.0.1"}]; ok {
+		t.Error("ip failures counted")
+	}
+	if err := s.Check(ctx, "+77019999999", 1, "10.0.0.1"); err != nil {
+		t.Errorf("Check() of fresh account error = %v", err)
+	}
+}
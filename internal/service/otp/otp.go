@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	repository "github.com/ei-jobs/auth-service/internal/repository/otp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCode     = apperr.Invalid("code", "is invalid or expired").WithReason("INVALID_CODE")
+	ErrTooManyAttempts = apperr.New(apperr.FailedPrecondition, "TOO_MANY_ATTEMPTS", "too many attempts, request a new code")
+	ErrResendTooSoon   = apperr.New(apperr.ResourceExhausted, "RESEND_TOO_SOON", "code was sent recently, try again later")
+)
+
+type OTPRepository interface {
+	StoreCode(ctx context.Context, code *model.OTPCode) error
+	GetLatestCode(ctx context.Context, phone string, appId int32, purpose string) (model.OTPCode, error)
+	IncrementAttempts(ctx context.Context, id int64, maxAttempts int) (bool, error)
+	ConsumeCode(ctx context.Context, id int64) (bool, error)
+}
+
+type SMSSender interface {
+	Send(ctx context.Context, phone string, message string) error
+}
+
+// OTPService issues one-time codes over SMS. Only bcrypt hashes of the codes
+// are stored, a code expires after ttl, can be checked maxAttempts times and
+// a new one cannot be requested more often than every resendInterval.
+type OTPService struct {
+	log            *slog.Logger
+	repository     OTPRepository
+	sender         SMSSender
+	length         int
+	ttl            time.Duration
+	maxAttempts    int
+	resendInterval time.Duration
+}
+
+func NewOTPService(log *slog.Logger, repository OTPRepository, sender SMSSender, length int, ttl time.Duration, maxAttempts int, resendInterval time.Duration) *OTPService {
+	return &OTPService{
+		log:            log,
+		repository:     repository,
+		sender:         sender,
+		length:         length,
+		ttl:            ttl,
+		maxAttempts:    maxAttempts,
+		resendInterval: resendInterval,
+	}
+}
+
+// Send texts a new code for purpose to the phone. A phone can be sent a
+// code at most once per resendInterval.
+func (s *OTPService) Send(ctx context.Context, phone string, appId int32, purpose string) error {
+	const op = "otpservice.Send"
+
+	code, err := s.issue(ctx, phone, appId, purpose)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	message := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(s.ttl.Minutes()))
+	if err := s.sender.Send(ctx, phone, message); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Throttle counts a code request for a phone that has no account like
+// Send would, without texting anything: it fails with ErrResendTooSoon in
+// the same cases and takes about as long. Callers use it so that their
+// answers do not tell registered phones from unknown ones. The code it
+// stores is never revealed, so it cannot be verified.
+func (s *OTPService) Throttle(ctx context.Context, phone string, appId int32, purpose string) error {
+	const op = "otpservice.Throttle"
+
+	if _, err := s.issue(ctx, phone, appId, purpose); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// issue stores a new code for purpose, unless one was issued for the phone
+// within resendInterval, and returns it.
+func (s *OTPService) issue(ctx context.Context, phone string, appId int32, purpose string) (string, error) {
+	latest, err := s.repository.GetLatestCode(ctx, phone, appId, purpose)
+	if err != nil && !errors.Is(err, repository.ErrCodeNotFound) {
+		return "", err
+	}
+	if err == nil && time.Since(latest.CreatedAt) < s.resendInterval {
+		return "", ErrResendTooSoon
+	}
+
+	code, err := s.generate()
+	if err != nil {
+		return "", err
+	}
+
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repository.StoreCode(ctx, &model.OTPCode{
+		Phone:     phone,
+		AppId:     appId,
+		Purpose:   purpose,
+		CodeHash:  codeHash,
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Verify checks code against the latest code sent for purpose and consumes
+// it on success, so every code can be used only once.
+func (s *OTPService) Verify(ctx context.Context, phone string, appId int32, purpose string, code string) error {
+	const op = "otpservice.Verify"
+
+	latest, err := s.repository.GetLatestCode(ctx, phone, appId, purpose)
+	if err != nil {
+		if errors.Is(err, repository.ErrCodeNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if latest.ConsumedAt != nil || time.Now().After(latest.ExpiresAt) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	allowed, err := s.repository.IncrementAttempts(ctx, latest.Id, s.maxAttempts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%s: %w", op, ErrTooManyAttempts)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(latest.CodeHash, []byte(code)); err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	consumed, err := s.repository.ConsumeCode(ctx, latest.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !consumed {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	return nil
+}
+
+func (s *OTPService) generate() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(s.length)), nil)
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", s.length, n), nil
+}
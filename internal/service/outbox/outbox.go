@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/backoff"
+)
+
+type OutboxRepository interface {
+	ClaimEvents(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]model.Event, error)
+	MarkPublished(ctx context.Context, id int64, now time.Time) error
+	MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// EventPublisher delivers domain events to other services. Events may be
+// delivered more than once, so consumers must deduplicate by event id.
+type EventPublisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+const (
+	// claimLease is how long a claimed batch is kept from other relays. It
+	// has to outlast publishing a whole batch.
+	claimLease      = time.Minute
+	cleanupInterval = time.Hour
+	baseRetryDelay  = time.Second
+	maxRetryDelay   = 10 * time.Minute
+)
+
+// OutboxService relays the events queued in the outbox to the publisher in
+// the order they were queued. A failed event is retried with backoff
+// without holding up the ones after it, so it may arrive after them.
+// Published events are kept for retention before they are deleted.
+type OutboxService struct {
+	log          *slog.Logger
+	repository   OutboxRepository
+	publisher    EventPublisher
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+}
+
+func NewOutboxService(log *slog.Logger, repository OutboxRepository, publisher EventPublisher, pollInterval time.Duration, batchSize int, retention time.Duration) *OutboxService {
+	return &OutboxService{
+		log:          log,
+		repository:   repository,
+		publisher:    publisher,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		retention:    retention,
+	}
+}
+
+// RunRelay relays events until ctx is cancelled. A full batch is followed
+// by the next one right away; otherwise the relay waits for pollInterval.
+func (s *OutboxService) RunRelay(ctx context.Context) {
+	const op = "outboxservice.RunRelay"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+
+	for {
+		if time.Since(lastCleanup) >= cleanupInterval {
+			if _, err := s.repository.DeletePublished(ctx, time.Now().Add(-s.retention)); err != nil {
+				log.Error("failed to delete published events", slog.String("error", err.Error()))
+			}
+			lastCleanup = time.Now()
+		}
+
+		claimed, err := s.relayBatch(ctx)
+		if err != nil {
+			log.Error("failed to relay events", slog.String("error", err.Error()))
+		}
+
+		if claimed == s.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch claims a batch of due events and publishes them. It returns
+// how many events it claimed.
+func (s *OutboxService) relayBatch(ctx context.Context) (int, error) {
+	events, err := s.repository.ClaimEvents(ctx, s.batchSize, time.Now(), claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := s.publish(ctx, event); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+func (s *OutboxService) publish(ctx context.Context, event model.Event) error {
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		s.log.Warn("failed to publish event",
+			slog.Int64("event_id", event.Id),
+			slog.String("type", event.Type),
+			slog.Int("attempts", event.Attempts+1),
+			slog.String("error", err.Error()),
+		)
+
+		return s.repository.MarkFailed(ctx, event.Id, time.Now().Add(backoff.Delay(event.Attempts, baseRetryDelay, maxRetryDelay)), err.Error())
+	}
+
+	return s.repository.MarkPublished(ctx, event.Id, time.Now())
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	repository "github.com/ei-jobs/auth-service/internal/repository/rbac"
+)
+
+type RBACRepository interface {
+	CreateRole(ctx context.Context, role *model.Role) error
+	GetRole(ctx context.Context, roleId int64) (model.Role, error)
+	ListRoles(ctx context.Context, appId int32) ([]model.Role, error)
+	ListUserRoles(ctx context.Context, userId int64, appId int32) ([]model.Role, error)
+	DeleteRole(ctx context.Context, roleId int64) (bool, error)
+	CreatePermission(ctx context.Context, permission *model.Permission) error
+	GetPermission(ctx context.Context, permissionId int64) (model.Permission, error)
+	ListPermissions(ctx context.Context, appId int32) ([]model.Permission, error)
+	DeletePermission(ctx context.Context, permissionId int64) (bool, error)
+	GrantPermission(ctx context.Context, roleId int64, permissionId int64) error
+	RevokePermission(ctx context.Context, roleId int64, permissionId int64) (bool, error)
+	AssignRole(ctx context.Context, userId int64, roleId int64) error
+	RevokeRole(ctx context.Context, userId int64, roleId int64) (bool, error)
+	GetUserAppId(ctx context.Context, userId int64) (int32, error)
+	GetUserAccess(ctx context.Context, userId int64, appId int32) ([]string, []string, error)
+}
+
+var (
+	ErrInvalidName        = apperr.Invalid("name", "must be a lowercase identifier of at most 64 characters").WithReason("INVALID_NAME")
+	ErrRoleExists         = apperr.New(apperr.AlreadyExists, "ROLE_EXISTS", "role already exists")
+	ErrRoleNotFound       = apperr.New(apperr.NotFound, "ROLE_NOT_FOUND", "role not found")
+	ErrPermissionExists   = apperr.New(apperr.AlreadyExists, "PERMISSION_EXISTS", "permission already exists")
+	ErrPermissionNotFound = apperr.New(apperr.NotFound, "PERMISSION_NOT_FOUND", "permission not found")
+	ErrUserNotFound       = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+)
+
+// Names end up space-separated in compact token claims, so they are limited
+// to lowercase identifiers such as "employer" or "vacancies:write".
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,63}$`)
+
+// RBACService manages the roles and permissions of each app. Every method
+// takes the app the caller is acting in, and entities of other apps are
+// reported as not found.
+type RBACService struct {
+	log        *slog.Logger
+	repository RBACRepository
+}
+
+func NewRBACService(log *slog.Logger, repository RBACRepository) *RBACService {
+	return &RBACService{
+		log:        log,
+		repository: repository,
+	}
+}
+
+func (s *RBACService) CreateRole(ctx context.Context, appId int32, name string, description string) (model.Role, error) {
+	const op = "rbacservice.CreateRole"
+
+	if !namePattern.MatchString(name) {
+		return model.Role{}, fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+
+	role := model.Role{
+		AppId:       appId,
+		Name:        name,
+		Description: optional(description),
+	}
+	if err := s.repository.CreateRole(ctx, &role); err != nil {
+		if errors.Is(err, repository.ErrRoleExists) {
+			return model.Role{}, fmt.Errorf("%s: %w", op, ErrRoleExists)
+		}
+		return model.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("role created", slog.Int("app_id", int(appId)), slog.String("role", name))
+
+	return role, nil
+}
+
+func (s *RBACService) ListRoles(ctx context.Context, appId int32) ([]model.Role, error) {
+	const op = "rbacservice.ListRoles"
+
+	roles, err := s.repository.ListRoles(ctx, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+func (s *RBACService) DeleteRole(ctx context.Context, appId int32, roleId int64) error {
+	const op = "rbacservice.DeleteRole"
+
+	if _, err := s.role(ctx, appId, roleId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := s.repository.DeleteRole(ctx, roleId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !deleted {
+		return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+	}
+
+	s.log.Info("role deleted", slog.Int("app_id", int(appId)), slog.Int64("role_id", roleId))
+
+	return nil
+}
+
+func (s *RBACService) CreatePermission(ctx context.Context, appId int32, name string, description string) (model.Permission, error) {
+	const op = "rbacservice.CreatePermission"
+
+	if !namePattern.MatchString(name) {
+		return model.Permission{}, fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+
+	permission := model.Permission{
+		AppId:       appId,
+		Name:        name,
+		Description: optional(description),
+	}
+	if err := s.repository.CreatePermission(ctx, &permission); err != nil {
+		if errors.Is(err, repository.ErrPermissionExists) {
+			return model.Permission{}, fmt.Errorf("%s: %w", op, ErrPermissionExists)
+		}
+		return model.Permission{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("permission created", slog.Int("app_id", int(appId)), slog.String("permission", name))
+
+	return permission, nil
+}
+
+func (s *RBACService) ListPermissions(ctx context.Context, appId int32) ([]model.Permission, error) {
+	const op = "rbacservice.ListPermissions"
+
+	permissions, err := s.repository.ListPermissions(ctx, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+func (s *RBACService) DeletePermission(ctx context.Context, appId int32, permissionId int64) error {
+	const op = "rbacservice.DeletePermission"
+
+	if _, err := s.permission(ctx, appId, permissionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := s.repository.DeletePermission(ctx, permissionId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !deleted {
+		return fmt.Errorf("%s: %w", op, ErrPermissionNotFound)
+	}
+
+	s.log.Info("permission deleted", slog.Int("app_id", int(appId)), slog.Int64("permission_id", permissionId))
+
+	return nil
+}
+
+func (s *RBACService) GrantPermission(ctx context.Context, appId int32, roleId int64, permissionId int64) error {
+	const op = "rbacservice.GrantPermission"
+
+	if _, err := s.role(ctx, appId, roleId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.permission(ctx, appId, permissionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repository.GrantPermission(ctx, roleId, permissionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokePermission reports whether the role held the permission.
+func (s *RBACService) RevokePermission(ctx context.Context, appId int32, roleId int64, permissionId int64) (bool, error) {
+	const op = "rbacservice.RevokePermission"
+
+	if _, err := s.role(ctx, appId, roleId); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := s.repository.RevokePermission(ctx, roleId, permissionId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+func (s *RBACService) AssignRole(ctx context.Context, appId int32, userId int64, roleId int64) error {
+	const op = "rbacservice.AssignRole"
+
+	if err := s.checkUser(ctx, appId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := s.role(ctx, appId, roleId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repository.AssignRole(ctx, userId, roleId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("role assigned", slog.Int64("user_id", userId), slog.String("role", role.Name))
+
+	return nil
+}
+
+// RevokeRole reports whether the user held the role. Tokens already issued
+// keep the role until they are refreshed.
+func (s *RBACService) RevokeRole(ctx context.Context, appId int32, userId int64, roleId int64) (bool, error) {
+	const op = "rbacservice.RevokeRole"
+
+	role, err := s.role(ctx, appId, roleId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := s.repository.RevokeRole(ctx, userId, roleId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if revoked {
+		s.log.Info("role revoked", slog.Int64("user_id", userId), slog.String("role", role.Name))
+	}
+
+	return revoked, nil
+}
+
+func (s *RBACService) ListUserRoles(ctx context.Context, appId int32, userId int64) ([]model.Role, error) {
+	const op = "rbacservice.ListUserRoles"
+
+	if err := s.checkUser(ctx, appId, userId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := s.repository.ListUserRoles(ctx, userId, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UserAccess returns the role and permission names to embed into the user's
+// access token.
+func (s *RBACService) UserAccess(ctx context.Context, userId int64, appId int32) ([]string, []string, error) {
+	const op = "rbacservice.UserAccess"
+
+	roles, permissions, err := s.repository.GetUserAccess(ctx, userId, appId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, permissions, nil
+}
+
+func (s *RBACService) role(ctx context.Context, appId int32, roleId int64) (model.Role, error) {
+	role, err := s.repository.GetRole(ctx, roleId)
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return model.Role{}, ErrRoleNotFound
+		}
+		return model.Role{}, err
+	}
+
+	if role.AppId != appId {
+		return model.Role{}, ErrRoleNotFound
+	}
+
+	return role, nil
+}
+
+func (s *RBACService) permission(ctx context.Context, appId int32, permissionId int64) (model.Permission, error) {
+	permission, err := s.repository.GetPermission(ctx, permissionId)
+	if err != nil {
+		if errors.Is(err, repository.ErrPermissionNotFound) {
+			return model.Permission{}, ErrPermissionNotFound
+		}
+		return model.Permission{}, err
+	}
+
+	if permission.AppId != appId {
+		return model.Permission{}, ErrPermissionNotFound
+	}
+
+	return permission, nil
+}
+
+func (s *RBACService) checkUser(ctx context.Context, appId int32, userId int64) error {
+	userAppId, err := s.repository.GetUserAppId(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if userAppId != appId {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
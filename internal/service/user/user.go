@@ -1,20 +1,398 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	repository "github.com/ei-jobs/auth-service/internal/repository/user"
+)
+
+type UserRepository interface {
+	GetUser(ctx context.Context, userId int64) (model.User, error)
+	GetUsers(ctx context.Context, userIds []int64) ([]model.User, error)
+	UpdateProfile(ctx context.Context, userId int64, name string, avatarUrl *string, description *string) (model.User, error)
+	SuspendUser(ctx context.Context, userId int64, appId int32, reason string, suspendedBy *int64, until *time.Time, now time.Time) (model.User, error)
+	UnsuspendUser(ctx context.Context, userId int64, appId int32) (model.User, error)
+	DeleteUser(ctx context.Context, userId int64, now time.Time) (model.User, error)
+	RestoreUser(ctx context.Context, userId int64, appId int32, deletedAfter time.Time) (model.User, error)
+	AnonymizeUsers(ctx context.Context, deletedBefore time.Time, now time.Time) (int64, error)
+	PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	ListUsers(ctx context.Context, filter model.UserFilter, beforeId int64, limit int) ([]model.User, error)
+	CountUsers(ctx context.Context, filter model.UserFilter) (int64, error)
+}
+
+// Auditor records security-relevant events in the audit log.
+type Auditor interface {
+	Record(ctx context.Context, event model.AuditEvent)
+}
+
+var (
+	ErrUserNotFound      = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+	ErrInvalidPageToken  = apperr.Invalid("page_token", "is invalid").WithReason("INVALID_PAGE_TOKEN")
+	ErrBatchTooLarge     = apperr.Invalid("user_ids", fmt.Sprintf("must not contain more than %d ids", maxBatchSize)).WithReason("BATCH_TOO_LARGE")
+	ErrUserNotDeleted    = apperr.New(apperr.FailedPrecondition, "USER_NOT_DELETED", "user is not deleted")
+	ErrUserExists        = apperr.New(apperr.AlreadyExists, "USER_EXISTS", "another user has registered with the same phone")
+	ErrSuspensionExpired = apperr.Invalid("expires_at", "must be in the future").WithReason("SUSPENSION_EXPIRED")
+)
+
+// Purge modes: anonymised users keep their row and id with the personal
+// data scrubbed, deleted ones are removed for good.
+const (
+	PurgeAnonymize = "anonymize"
+	PurgeDelete    = "delete"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	maxBatchSize    = 100
+	purgeInterval   = time.Hour
 )
 
-type UserRespository interface {
+// Profile holds the fields of a user that can be changed through the user
+// API. Empty avatar URLs and descriptions are stored as NULL.
+type Profile struct {
+	Name        string
+	AvatarUrl   string
+	Description string
 }
 
+// UserService manages user profiles. Credentials, sessions and tokens stay
+// with the auth service. Deleted users can be restored for restorePeriod,
+// after which RunPurge anonymises or removes them according to purgeMode.
 type UserService struct {
-    log *slog.Logger
-    repository UserRespository 
+	log           *slog.Logger
+	repository    UserRepository
+	audit         Auditor
+	restorePeriod time.Duration
+	purgeMode     string
+}
+
+func NewUserService(log *slog.Logger, repository UserRepository, audit Auditor, restorePeriod time.Duration, purgeMode string) *UserService {
+	switch purgeMode {
+	case PurgeAnonymize, PurgeDelete:
+	default:
+		panic("unknown user purge mode: " + purgeMode)
+	}
+
+	return &UserService{
+		log:           log,
+		repository:    repository,
+		audit:         audit,
+		restorePeriod: restorePeriod,
+		purgeMode:     purgeMode,
+	}
+}
+
+func (s *UserService) GetUser(ctx context.Context, userId int64) (model.User, error) {
+	const op = "userservice.GetUser"
+
+	user, err := s.repository.GetUser(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// BatchGetUsers looks up several users at once. Users of other apps than
+// appId are reported as missing, unless appId is 0. Duplicate ids are
+// looked up once.
+func (s *UserService) BatchGetUsers(ctx context.Context, appId int32, userIds []int64) (found map[int64]model.User, missing []int64, err error) {
+	const op = "userservice.BatchGetUsers"
+
+	ids := make([]int64, 0, len(userIds))
+	seen := make(map[int64]struct{}, len(userIds))
+	for _, id := range userIds {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	if len(ids) > maxBatchSize {
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrBatchTooLarge)
+	}
+
+	users, err := s.repository.GetUsers(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	found = make(map[int64]model.User, len(users))
+	for _, user := range users {
+		if appId != 0 && user.AppId != appId {
+			continue
+		}
+		found[user.Id] = user
+	}
+
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	return found, missing, nil
+}
+
+func (s *UserService) UpdateUser(ctx context.Context, userId int64, profile Profile) (model.User, error) {
+	const op = "userservice.UpdateUser"
+
+	user, err := s.repository.UpdateProfile(ctx, userId, profile.Name, nullable(profile.AvatarUrl), nullable(profile.Description))
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("user updated", slog.Int64("user_id", user.Id))
+	s.record(ctx, model.AuditUserUpdated, user, nil)
+
+	return user, nil
+}
+
+// Suspension describes why and for how long a user is suspended. By is the
+// admin who suspended the user, or 0 for a service. A nil Until suspends
+// indefinitely.
+type Suspension struct {
+	Reason string
+	By     int64
+	Until  *time.Time
+}
+
+// SuspendUser stops the user from logging in or refreshing tokens and
+// revokes their sessions. appId limits it to users of one app unless it is
+// 0.
+func (s *UserService) SuspendUser(ctx context.Context, appId int32, userId int64, suspension Suspension) (model.User, error) {
+	const op = "userservice.SuspendUser"
+
+	now := time.Now()
+	if suspension.Until != nil && !suspension.Until.After(now) {
+		return model.User{}, fmt.Errorf("%s: %w", op, ErrSuspensionExpired)
+	}
+
+	var by *int64
+	if suspension.By != 0 {
+		by = &suspension.By
+	}
+
+	user, err := s.repository.SuspendUser(ctx, userId, appId, suspension.Reason, by, suspension.Until, now)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("user suspended",
+		slog.Int64("user_id", user.Id),
+		slog.Int64("suspended_by", suspension.By),
+	)
+
+	metadata := map[string]string{"reason": suspension.Reason}
+	if suspension.Until != nil {
+		metadata["suspended_until"] = suspension.Until.UTC().Format(time.RFC3339)
+	}
+	s.record(ctx, model.AuditUserSuspended, user, metadata)
+
+	return user, nil
+}
+
+// UnsuspendUser lifts the suspension of the user. Unsuspending a user who
+// is not suspended does nothing.
+func (s *UserService) UnsuspendUser(ctx context.Context, appId int32, userId int64) (model.User, error) {
+	const op = "userservice.UnsuspendUser"
+
+	user, err := s.repository.UnsuspendUser(ctx, userId, appId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("user unsuspended", slog.Int64("user_id", user.Id))
+	s.record(ctx, model.AuditUserUnsuspended, user, nil)
+
+	return user, nil
+}
+
+// DeleteUser marks the user as deleted and revokes their sessions. The user
+// disappears from every lookup at once but can be restored until the
+// restore period ends. It reports whether the user existed.
+func (s *UserService) DeleteUser(ctx context.Context, userId int64) (bool, error) {
+	const op = "userservice.DeleteUser"
+
+	user, err := s.repository.DeleteUser(ctx, userId, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("user deleted", slog.Int64("user_id", userId))
+	s.record(ctx, model.AuditUserDeleted, user, nil)
+
+	return true, nil
+}
+
+// RestoreUser brings back a user deleted within the restore period. appId
+// limits the restore to users of one app unless it is 0. Sessions revoked
+// by the deletion stay revoked, so the user has to log in again.
+func (s *UserService) RestoreUser(ctx context.Context, appId int32, userId int64) (model.User, error) {
+	const op = "userservice.RestoreUser"
+
+	user, err := s.repository.RestoreUser(ctx, userId, appId, time.Now().Add(-s.restorePeriod))
+	if err == nil {
+		s.log.Info("user restored", slog.Int64("user_id", user.Id))
+		s.record(ctx, model.AuditUserRestored, user, nil)
+		return user, nil
+	}
+
+	if errors.Is(err, repository.ErrUserExists) {
+		return model.User{}, fmt.Errorf("%s: %w", op, ErrUserExists)
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Nothing to restore: tell a live user apart from a missing one.
+	live, err := s.repository.GetUser(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return model.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if appId != 0 && live.AppId != appId {
+		return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	return model.User{}, fmt.Errorf("%s: %w", op, ErrUserNotDeleted)
+}
+
+// RunPurge periodically purges users whose restore period has ended.
+func (s *UserService) RunPurge(ctx context.Context) {
+	const op = "userservice.RunPurge"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.purge(ctx, time.Now())
+		if err != nil {
+			log.Error("failed to purge deleted users", slog.String("error", err.Error()))
+		} else if purged > 0 {
+			log.Info("purged deleted users", slog.Int64("count", purged), slog.String("mode", s.purgeMode))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *UserService) purge(ctx context.Context, now time.Time) (int64, error) {
+	deletedBefore := now.Add(-s.restorePeriod)
+
+	if s.purgeMode == PurgeDelete {
+		return s.repository.PurgeUsers(ctx, deletedBefore)
+	}
+
+	return s.repository.AnonymizeUsers(ctx, deletedBefore, now)
+}
+
+// ListUsers returns a page of users matching filter, newest first. Pages
+// are addressed by opaque tokens, so users created while paging do not
+// shift later pages.
+func (s *UserService) ListUsers(ctx context.Context, filter model.UserFilter, pageSize int, pageToken string) (model.UserPage, error) {
+	const op = "userservice.ListUsers"
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var beforeId int64
+	if pageToken != "" {
+		id, err := decodePageToken(pageToken)
+		if err != nil {
+			return model.UserPage{}, fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+		}
+		beforeId = id
+	}
+
+	// One extra user tells whether there is a next page.
+	users, err := s.repository.ListUsers(ctx, filter, beforeId, pageSize+1)
+	if err != nil {
+		return model.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	total, err := s.repository.CountUsers(ctx, filter)
+	if err != nil {
+		return model.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := model.UserPage{Users: users, TotalCount: total}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+		page.NextPageToken = encodePageToken(page.Users[pageSize-1].Id)
+	}
+
+	return page, nil
+}
+
+// record audits an action of the caller on user.
+func (s *UserService) record(ctx context.Context, eventType string, user model.User, metadata map[string]string) {
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:         eventType,
+		AppId:        user.AppId,
+		TargetUserId: user.Id,
+		Metadata:     metadata,
+	})
+}
+
+func encodePageToken(lastId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastId, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid page token")
+	}
+
+	return id, nil
 }
 
-func NewUserService(log *slog.Logger, repository UserRespository) *UserService {
-    return &UserService{
-        log: log,
-        repository: repository,
-    }
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	repository "github.com/ei-jobs/auth-service/internal/repository/user"
+)
+
+const testRestorePeriod = 30 * 24 * time.Hour
+
+type fakeUserRepository struct {
+	users map[int64]model.User
+
+	getUsersCalls [][]int64
+	anonymized    []time.Time
+	purged        []time.Time
+}
+
+func newFakeUserRepository(users ...model.User) *fakeUserRepository {
+	r := &fakeUserRepository{users: make(map[int64]model.User)}
+	for _, user := range users {
+		r.users[user.Id] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepository) GetUser(_ context.Context, userId int64) (model.User, error) {
+	user, ok := r.users[userId]
+	if !ok {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepository) GetUsers(_ context.Context, userIds []int64) ([]model.User, error) {
+	r.getUsersCalls = append(r.getUsersCalls, userIds)
+
+	var users []model.User
+	for _, id := range userIds {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) UpdateProfile(context.Context, int64, string, *string, *string) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeUserRepository) SuspendUser(context.Context, int64, int32, string, *int64, *time.Time, time.Time) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeUserRepository) UnsuspendUser(context.Context, int64, int32) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeUserRepository) DeleteUser(context.Context, int64, time.Time) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeUserRepository) RestoreUser(context.Context, int64, int32, time.Time) (model.User, error) {
+	return model.User{}, errors.New("not implemented")
+}
+
+func (r *fakeUserRepository) AnonymizeUsers(_ context.Context, deletedBefore time.Time, _ time.Time) (int64, error) {
+	r.anonymized = append(r.anonymized, deletedBefore)
+	return 1, nil
+}
+
+func (r *fakeUserRepository) PurgeUsers(_ context.Context, deletedBefore time.Time) (int64, error) {
+	r.purged = append(r.purged, deletedBefore)
+	return 1, nil
+}
+
+func (r *fakeUserRepository) ListUsers(_ context.Context, filter model.UserFilter, beforeId int64, limit int) ([]model.User, error) {
+	var users []model.User
+	for _, user := range r.users {
+		if filter.AppId != 0 && user.AppId != filter.AppId {
+			continue
+		}
+		if beforeId != 0 && user.Id >= beforeId {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Id > users[j].Id })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) CountUsers(_ context.Context, filter model.UserFilter) (int64, error) {
+	var count int64
+	for _, user := range r.users {
+		if filter.AppId == 0 || user.AppId == filter.AppId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakeAuditor struct{}
+
+func (fakeAuditor) Record(context.Context, model.AuditEvent) {}
+
+func newTestUserService(repo UserRepository, purgeMode string) *UserService {
+	return NewUserService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, fakeAuditor{}, testRestorePeriod, purgeMode)
+}
+
+// usersOf returns users 1 to n. Odd ids belong to app 1, even ones to app 2.
+func usersOf(n int) []model.User {
+	users := make([]model.User, 0, n)
+	for i := 1; i <= n; i++ {
+		users = append(users, model.User{Id: int64(i), AppId: int32(2 - i%2)})
+	}
+	return users
+}
+
+func TestListUsersPaging(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserService(newFakeUserRepository(usersOf(10)...), PurgeAnonymize)
+	filter := model.UserFilter{AppId: 1}
+
+	var (
+		ids       []int64
+		pageToken string
+		pages     int
+	)
+	for {
+		page, err := s.ListUsers(ctx, filter, 2, pageToken)
+		if err != nil {
+			t.Fatalf("ListUsers() error = %v", err)
+		}
+		if page.TotalCount != 5 {
+			t.Errorf("TotalCount = %d, want 5", page.TotalCount)
+		}
+		for _, user := range page.Users {
+			ids = append(ids, user.Id)
+		}
+
+		pages++
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	want := []int64{9, 7, 5, 3, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+	}
+	if pages != 3 {
+		t.Errorf("pages = %d, want 3", pages)
+	}
+}
+
+func TestListUsersRejectsBadPageToken(t *testing.T) {
+	s := newTestUserService(newFakeUserRepository(usersOf(3)...), PurgeAnonymize)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "!!!"},
+		{name: "not a number", token: base64.RawURLEncoding.EncodeToString([]byte("abc"))},
+		{name: "zero id", token: base64.RawURLEncoding.EncodeToString([]byte("0"))},
+		{name: "negative id", token: base64.RawURLEncoding.EncodeToString([]byte("-5"))},
+		{name: "trailing bytes", token: encodePageToken(3) + "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ListUsers(context.Background(), model.UserFilter{}, 10, tt.token)
+			if !errors.Is(err, ErrInvalidPageToken) {
+				t.Errorf("ListUsers() error = %v, want %v", err, ErrInvalidPageToken)
+			}
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	repo := newFakeUserRepository(usersOf(4)...)
+	s := newTestUserService(repo, PurgeAnonymize)
+
+	found, missing, err := s.BatchGetUsers(context.Background(), 1, []int64{2, 3, 2, 4, 99, 4})
+	if err != nil {
+		t.Fatalf("BatchGetUsers() error = %v", err)
+	}
+
+	if got := repo.getUsersCalls[0]; len(got) != 4 {
+		t.Errorf("looked up %v, want each id once", got)
+	}
+
+	if len(found) != 1 || found[3].Id != 3 {
+		t.Errorf("found = %v, want only user 3 of app 1", found)
+	}
+
+	wantMissing := []int64{2, 4, 99}
+	if len(missing) != len(wantMissing) {
+		t.Fatalf("missing = %v, want %v", missing, wantMissing)
+	}
+	for i := range wantMissing {
+		if missing[i] != wantMissing[i] {
+			t.Fatalf("missing = %v, want %v", missing, wantMissing)
+		}
+	}
+}
+
+func TestBatchGetUsersAnyApp(t *testing.T) {
+	s := newTestUserService(newFakeUserRepository(usersOf(4)...), PurgeAnonymize)
+
+	found, missing, err := s.BatchGetUsers(context.Background(), 0, []int64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("BatchGetUsers() error = %v", err)
+	}
+	if len(found) != 4 || len(missing) != 0 {
+		t.Errorf("found %d, missing %v; want 4 found and none missing", len(found), missing)
+	}
+}
+
+func TestBatchGetUsersLimit(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestUserService(repo, PurgeAnonymize)
+
+	ids := make([]int64, 0, maxBatchSize+1)
+	for i := 1; i <= maxBatchSize; i++ {
+		ids = append(ids, int64(i))
+	}
+
+	// Duplicates do not count towards the limit.
+	if _, _, err := s.BatchGetUsers(context.Background(), 1, append(ids, 1)); err != nil {
+		t.Fatalf("BatchGetUsers() of %d distinct ids error = %v", maxBatchSize, err)
+	}
+
+	_, _, err := s.BatchGetUsers(context.Background(), 1, append(ids, maxBatchSize+1))
+	if !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("BatchGetUsers() error = %v, want %v", err, ErrBatchTooLarge)
+	}
+	if len(repo.getUsersCalls) != 1 {
+		t.Error("oversized batch reached the repository")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	deletedBefore := now.Add(-testRestorePeriod)
+
+	tests := []struct {
+		mode           string
+		wantAnonymized int
+		wantPurged     int
+	}{
+		{mode: PurgeAnonymize, wantAnonymized: 1},
+		{mode: PurgeDelete, wantPurged: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			repo := newFakeUserRepository()
+			s := newTestUserService(repo, tt.mode)
+
+			if _, err := s.purge(context.Background(), now); err != nil {
+				t.Fatalf("purge() error = %v", err)
+			}
+
+			if len(repo.anonymized) != tt.wantAnonymized || len(repo.purged) != tt.wantPurged {
+				t.Fatalf("anonymized %d, purged %d; want %d and %d", len(repo.anonymized), len(repo.purged), tt.wantAnonymized, tt.wantPurged)
+			}
+			for _, got := range append(repo.anonymized, repo.purged...) {
+				if !got.Equal(deletedBefore) {
+					t.Errorf("deletedBefore = %v, want %v", got, deletedBefore)
+				}
+			}
+		})
+	}
+}
+
+func TestNewUserServiceUnknownPurgeMode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewUserService() did not panic")
+		}
+	}()
+
+	newTestUserService(newFakeUserRepository(), "shred")
+}
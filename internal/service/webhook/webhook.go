@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ei-jobs/auth-service/internal/domain/apperr"
+	"github.com/ei-jobs/auth-service/internal/domain/model"
+	"github.com/ei-jobs/auth-service/internal/lib/backoff"
+	"github.com/ei-jobs/auth-service/internal/lib/opaque"
+	"github.com/ei-jobs/auth-service/internal/lib/webhook"
+	repository "github.com/ei-jobs/auth-service/internal/repository/webhook"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error
+	ListSubscriptions(ctx context.Context, appId int32) ([]model.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, appId int32, id int64) (bool, error)
+	EnqueueDeliveries(ctx context.Context, event model.Event, payload []byte, now time.Time) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int, now time.Time) error
+	MarkFailed(ctx context.Context, id int64, status string, nextAttemptAt time.Time, statusCode int, lastError string) error
+	GetDelivery(ctx context.Context, appId int32, id int64) (model.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, appId int32, subscriptionId int64, status string, beforeId int64, limit int) ([]model.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, appId int32, id int64, now time.Time) (model.WebhookDelivery, error)
+}
+
+// SecretBox encrypts webhook secrets at rest, like app secrets.
+type SecretBox interface {
+	Seal(plaintext string) (string, error)
+	Open(value string) (string, error)
+}
+
+// Sender posts a signed webhook request and returns the response status,
+// or 0 if there was no response.
+type Sender interface {
+	Send(ctx context.Context, url string, secret string, deliveryId int64, eventType string, body []byte) (int, error)
+}
+
+var (
+	ErrInvalidURL       = apperr.Invalid("url", "must be an absolute https URL of a public host of at most 2048 characters").WithReason("INVALID_WEBHOOK_URL")
+	ErrInvalidEventType = apperr.Invalid("event_types", "contains an unknown event type").WithReason("INVALID_EVENT_TYPE")
+	ErrInvalidStatus    = apperr.Invalid("status", "must be pending, delivered or dead").WithReason("INVALID_DELIVERY_STATUS")
+	ErrInvalidPageToken = apperr.Invalid("page_token", "is invalid").WithReason("INVALID_PAGE_TOKEN")
+	ErrWebhookNotFound  = apperr.New(apperr.NotFound, "WEBHOOK_NOT_FOUND", "webhook not found")
+	ErrDeliveryNotFound = apperr.New(apperr.NotFound, "WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery not found")
+	ErrDeliveryPending  = apperr.New(apperr.FailedPrecondition, "WEBHOOK_DELIVERY_PENDING", "webhook delivery is still pending")
+)
+
+const (
+	secretSize      = 32
+	maxURLLength    = 2048
+	defaultPageSize = 50
+	maxPageSize     = 200
+	baseRetryDelay  = 10 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+	// leaseMargin is added to the request timeout for the lease on a
+	// claimed batch, whose requests are sent concurrently.
+	leaseMargin = 30 * time.Second
+)
+
+// WebhookService manages the apps' webhook subscriptions and delivers their
+// events. Publish queues a delivery per matching subscription, and
+// RunDelivery sends them, retrying failures with exponential backoff until
+// maxAttempts, after which a delivery is dead until it is replayed.
+type WebhookService struct {
+	log          *slog.Logger
+	repository   WebhookRepository
+	secrets      SecretBox
+	sender       Sender
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	timeout      time.Duration
+	allowHTTP    bool
+}
+
+func NewWebhookService(
+	log *slog.Logger,
+	repository WebhookRepository,
+	secrets SecretBox,
+	sender Sender,
+	pollInterval time.Duration,
+	batchSize int,
+	maxAttempts int,
+	timeout time.Duration,
+	allowHTTP bool,
+) *WebhookService {
+	return &WebhookService{
+		log:          log,
+		repository:   repository,
+		secrets:      secrets,
+		sender:       sender,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		timeout:      timeout,
+		allowHTTP:    allowHTTP,
+	}
+}
+
+// CreateWebhook subscribes url to the app's events of eventTypes, or to all
+// of them if eventTypes is empty. It returns the subscription with its
+// generated signing secret, which is not retrievable afterwards.
+func (s *WebhookService) CreateWebhook(ctx context.Context, appId int32, rawURL string, eventTypes []string) (model.WebhookSubscription, error) {
+	const op = "webhookservice.CreateWebhook"
+
+	rawURL = strings.TrimSpace(rawURL)
+	if !s.validURL(rawURL) {
+		return model.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+
+	types := make([]string, 0, len(eventTypes))
+	seen := make(map[string]bool, len(eventTypes))
+	for _, t := range eventTypes {
+		if !model.IsEventType(t) {
+			return model.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrInvalidEventType.WithMessage("unknown event type "+t))
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+
+	secret, err := opaque.New(secretSize)
+	if err != nil {
+		return model.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return model.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription := model.WebhookSubscription{
+		AppId:      appId,
+		URL:        rawURL,
+		Secret:     sealed,
+		EventTypes: types,
+	}
+	if err := s.repository.CreateSubscription(ctx, &subscription); err != nil {
+		return model.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("webhook created", slog.Int64("webhook_id", subscription.Id), slog.Int("app_id", int(appId)))
+
+	subscription.Secret = secret
+
+	return subscription, nil
+}
+
+// ListWebhooks returns the app's subscriptions without their secrets.
+func (s *WebhookService) ListWebhooks(ctx context.Context, appId int32) ([]model.WebhookSubscription, error) {
+	const op = "webhookservice.ListWebhooks"
+
+	subscriptions, err := s.repository.ListSubscriptions(ctx, appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhook removes the subscription and drops its deliveries, pending
+// ones included.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, appId int32, webhookId int64) error {
+	const op = "webhookservice.DeleteWebhook"
+
+	deleted, err := s.repository.DeleteSubscription(ctx, appId, webhookId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !deleted {
+		return fmt.Errorf("%s: %w", op, ErrWebhookNotFound)
+	}
+
+	s.log.Info("webhook deleted", slog.Int64("webhook_id", webhookId), slog.Int("app_id", int(appId)))
+
+	return nil
+}
+
+// ListDeliveries returns a page of the app's deliveries, newest first. A
+// zero webhookId or empty status does not filter.
+func (s *WebhookService) ListDeliveries(ctx context.Context, appId int32, webhookId int64, status string, pageSize int, pageToken string) ([]model.WebhookDelivery, string, error) {
+	const op = "webhookservice.ListDeliveries"
+
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidStatus)
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var beforeId int64
+	if pageToken != "" {
+		id, err := decodePageToken(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+		}
+		beforeId = id
+	}
+
+	// One extra delivery tells whether there is a next page.
+	deliveries, err := s.repository.ListDeliveries(ctx, appId, webhookId, status, beforeId, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nextPageToken string
+	if len(deliveries) > pageSize {
+		deliveries = deliveries[:pageSize]
+		nextPageToken = encodePageToken(deliveries[pageSize-1].Id)
+	}
+
+	return deliveries, nextPageToken, nil
+}
+
+// ReplayDelivery sends a delivered or dead delivery again, with a fresh set
+// of attempts. Pending deliveries yield ErrDeliveryPending.
+func (s *WebhookService) ReplayDelivery(ctx context.Context, appId int32, deliveryId int64) (model.WebhookDelivery, error) {
+	const op = "webhookservice.ReplayDelivery"
+
+	delivery, err := s.repository.ReplayDelivery(ctx, appId, deliveryId, time.Now())
+	if err != nil {
+		if !errors.Is(err, repository.ErrDeliveryNotFound) {
+			return model.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Nothing was replayed; tell a missing delivery from a pending one.
+		if _, err := s.repository.GetDelivery(ctx, appId, deliveryId); err != nil {
+			if errors.Is(err, repository.ErrDeliveryNotFound) {
+				return model.WebhookDelivery{}, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+			}
+			return model.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return model.WebhookDelivery{}, fmt.Errorf("%s: %w", op, ErrDeliveryPending)
+	}
+
+	s.log.Info("webhook delivery replayed", slog.Int64("delivery_id", deliveryId), slog.Int("app_id", int(appId)))
+
+	return delivery, nil
+}
+
+// Publish queues the event for every subscription that wants it. It is the
+// outbox relay's publisher, so an event may be published more than once;
+// it is queued once per subscription all the same.
+func (s *WebhookService) Publish(ctx context.Context, event model.Event) error {
+	const op = "webhookservice.Publish"
+
+	body, err := json.Marshal(event.Envelope())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.repository.EnqueueDeliveries(ctx, event, body, time.Now()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RunDelivery sends due deliveries until ctx is cancelled. A full batch is
+// followed by the next one right away; otherwise it waits for pollInterval.
+func (s *WebhookService) RunDelivery(ctx context.Context) {
+	const op = "webhookservice.RunDelivery"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := s.deliverBatch(ctx)
+		if err != nil {
+			log.Error("failed to deliver webhooks", slog.String("error", err.Error()))
+		}
+
+		if claimed == s.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverBatch claims a batch of due deliveries and sends them
+// concurrently, so that one slow endpoint does not hold up the others. It
+// returns how many deliveries it claimed.
+func (s *WebhookService) deliverBatch(ctx context.Context) (int, error) {
+	deliveries, err := s.repository.ClaimDeliveries(ctx, s.batchSize, time.Now(), s.timeout+leaseMargin)
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, len(deliveries))
+
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), errors.Join(errs...)
+}
+
+// deliver sends one delivery and records the outcome. Any failure,
+// including an error response, is retried.
+func (s *WebhookService) deliver(ctx context.Context, delivery model.WebhookDelivery) error {
+	statusCode, err := s.send(ctx, delivery)
+	if err == nil {
+		return s.repository.MarkDelivered(ctx, delivery.Id, statusCode, time.Now())
+	}
+
+	attempts := delivery.Attempts + 1
+	status := model.DeliveryPending
+	if attempts >= s.maxAttempts {
+		status = model.DeliveryDead
+	}
+
+	log := s.log.Warn
+	if status == model.DeliveryDead {
+		log = s.log.Error
+	}
+	log("failed to deliver webhook",
+		slog.Int64("delivery_id", delivery.Id),
+		slog.Int64("webhook_id", delivery.SubscriptionId),
+		slog.String("type", delivery.EventType),
+		slog.Int("attempts", attempts),
+		slog.String("status", status),
+		slog.String("error", err.Error()),
+	)
+
+	return s.repository.MarkFailed(ctx, delivery.Id, status, time.Now().Add(backoff.Delay(delivery.Attempts, baseRetryDelay, maxRetryDelay)), statusCode, err.Error())
+}
+
+func (s *WebhookService) send(ctx context.Context, delivery model.WebhookDelivery) (int, error) {
+	secret, err := s.secrets.Open(delivery.Subscription.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.sender.Send(ctx, delivery.Subscription.URL, secret, delivery.Id, delivery.EventType, delivery.Payload)
+}
+
+// validURL reports whether rawURL is an absolute https URL, or http if
+// allowHTTP is set, without credentials. Localhost names and forbidden IP
+// literals are refused right away; the addresses other hosts resolve to are
+// checked by the sender when it connects.
+func (s *WebhookService) validURL(rawURL string) bool {
+	if len(rawURL) > maxURLLength {
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.User != nil {
+		return false
+	}
+
+	if u.Scheme != "https" && !(s.allowHTTP && u.Scheme == "http") {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil && webhook.ForbiddenIP(ip) {
+		return false
+	}
+
+	return true
+}
+
+func encodePageToken(lastId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastId, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid page token")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,4 @@
+ALTER TABLE apps DROP COLUMN IF EXISTS login_methods;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_min_length;
+ALTER TABLE apps DROP COLUMN IF EXISTS refresh_ttl_seconds;
+ALTER TABLE apps DROP COLUMN IF EXISTS access_ttl_seconds;
@@ -0,0 +1,4 @@
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS access_ttl_seconds INT NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS refresh_ttl_seconds INT NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_min_length INT NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS login_methods TEXT[] NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_app_id_phone;
+
+CREATE INDEX IF NOT EXISTS idx_phone ON users (phone);
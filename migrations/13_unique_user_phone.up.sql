@@ -0,0 +1,33 @@
+-- Duplicate phones within an app cannot be merged automatically, since each
+-- row is a separate account with its own password, tokens and roles. The
+-- migration stops instead, and the duplicates have to be resolved by hand
+-- before running it again. They are listed by
+--
+--     SELECT app_id, phone, array_agg(id ORDER BY id) AS user_ids
+--     FROM users
+--     GROUP BY app_id, phone
+--     HAVING count(*) > 1;
+--
+-- and usually the account that was used last is kept, while the others get
+-- their phone changed or are deleted.
+DO $$
+DECLARE
+    duplicates INT;
+BEGIN
+    SELECT count(*) INTO duplicates
+    FROM (
+        SELECT 1
+        FROM users
+        GROUP BY app_id, phone
+        HAVING count(*) > 1
+    ) d;
+
+    IF duplicates > 0 THEN
+        RAISE EXCEPTION '% phones are registered more than once in the same app; resolve them as described in 13_unique_user_phone.up.sql', duplicates;
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_phone;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_id_phone ON users (app_id, phone);
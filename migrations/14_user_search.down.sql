@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_phone_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
+DROP INDEX IF EXISTS idx_users_app_id_created_at;
+DROP INDEX IF EXISTS idx_users_app_id_id;
+
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
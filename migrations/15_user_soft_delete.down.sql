@@ -0,0 +1,10 @@
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_app_id_phone;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_id_phone ON users (app_id, phone);
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS purged_at,
+    DROP COLUMN IF EXISTS deleted_at;
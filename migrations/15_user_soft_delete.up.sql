@@ -0,0 +1,9 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS purged_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
+
+-- A deleted user no longer holds their phone, so it can be registered again.
+DROP INDEX IF EXISTS idx_users_app_id_phone;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_id_phone ON users (app_id, phone) WHERE deleted_at IS NULL;
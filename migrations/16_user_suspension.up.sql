@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS suspension_reason VARCHAR(1000) NULL,
+    ADD COLUMN IF NOT EXISTS suspended_by INT NULL REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE apps DROP COLUMN IF EXISTS password_check_breached;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_forbid_personal_info;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_require_symbol;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_require_digit;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_require_lowercase;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_require_uppercase;
+ALTER TABLE apps DROP COLUMN IF EXISTS password_max_length;
@@ -0,0 +1,7 @@
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_max_length INT NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_require_uppercase BOOLEAN NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_require_lowercase BOOLEAN NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_require_digit BOOLEAN NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_require_symbol BOOLEAN NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_forbid_personal_info BOOLEAN NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS password_check_breached BOOLEAN NULL;
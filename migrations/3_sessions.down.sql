@@ -0,0 +1,7 @@
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS fk_refresh_tokens_session;
+
+ALTER INDEX IF EXISTS idx_refresh_tokens_session RENAME TO idx_refresh_tokens_family;
+
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id INT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id, app_id);
+
+INSERT INTO sessions (id, user_id, app_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT
+    family_id,
+    MIN(user_id),
+    MIN(app_id),
+    MIN(created_at),
+    MAX(created_at),
+    MAX(expires_at),
+    CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_session FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE;
+
+ALTER INDEX IF EXISTS idx_refresh_tokens_family RENAME TO idx_refresh_tokens_session;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS signing_keys
+(
+    id SERIAL PRIMARY KEY,
+    kid VARCHAR(64) NOT NULL UNIQUE,
+    app_id INT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_app ON signing_keys (app_id);
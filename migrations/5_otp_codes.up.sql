@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS otp_codes
+(
+    id SERIAL PRIMARY KEY,
+    phone VARCHAR(255) NOT NULL,
+    app_id INT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    code_hash VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    consumed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_otp_codes_lookup ON otp_codes (phone, app_id, purpose, created_at);
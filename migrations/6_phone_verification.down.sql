@@ -0,0 +1,3 @@
+ALTER TABLE apps DROP COLUMN IF EXISTS allow_unverified_login;
+
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
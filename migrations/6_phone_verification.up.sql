@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMP NULL;
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS allow_unverified_login BOOLEAN NOT NULL DEFAULT TRUE;
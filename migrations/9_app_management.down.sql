@@ -0,0 +1,9 @@
+ALTER TABLE apps DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE apps DROP COLUMN IF EXISTS created_at;
+ALTER TABLE apps DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE apps DROP COLUMN IF EXISTS secret_rotated_at;
+ALTER TABLE apps DROP COLUMN IF EXISTS previous_secret_expires_at;
+ALTER TABLE apps DROP COLUMN IF EXISTS previous_secret;
+
+ALTER TABLE apps ALTER COLUMN secret TYPE VARCHAR(255);
+ALTER TABLE apps ADD CONSTRAINT apps_secret_key UNIQUE (secret);
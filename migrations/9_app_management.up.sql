@@ -0,0 +1,9 @@
+ALTER TABLE apps DROP CONSTRAINT IF EXISTS apps_secret_key;
+ALTER TABLE apps ALTER COLUMN secret TYPE TEXT;
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS previous_secret TEXT NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMP NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS secret_rotated_at TIMESTAMP NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP NULL;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
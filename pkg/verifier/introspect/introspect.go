@@ -0,0 +1,94 @@
+// Package introspect checks tokens against the auth service's ValidateToken
+// RPC. It is kept apart from package verifier so that services verifying
+// tokens locally do not depend on the generated gRPC client.
+package introspect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ei-jobs/auth-service/pkg/verifier"
+	ssov1 "github.com/ei-jobs/protos/gen/go/sso"
+)
+
+// Checker is a verifier.RevocationChecker that asks the auth service's
+// ValidateToken RPC whether a token is still active. Answers are cached per
+// token id for ttl, so a revocation takes at most ttl to be noticed.
+type Checker struct {
+	client ssov1.AuthClient
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	revoked bool
+	until   time.Time
+}
+
+func NewChecker(client ssov1.AuthClient, ttl time.Duration) *Checker {
+	return &Checker{
+		client: client,
+		ttl:    ttl,
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+func (c *Checker) IsRevoked(ctx context.Context, token string, claims *verifier.Claims) (bool, error) {
+	const op = "introspect.IsRevoked"
+
+	now := time.Now()
+
+	if revoked, ok := c.cached(claims.TokenId, now); ok {
+		return revoked, nil
+	}
+
+	resp, err := c.client.ValidateToken(ctx, &ssov1.ValidateTokenRequest{Token: token})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked := !resp.GetActive()
+	c.store(claims.TokenId, revoked, now)
+
+	return revoked, nil
+}
+
+func (c *Checker) cached(tokenId string, now time.Time) (bool, bool) {
+	if tokenId == "" || c.ttl <= 0 {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[tokenId]
+	if !ok || now.After(entry.until) {
+		return false, false
+	}
+
+	return entry.revoked, true
+}
+
+func (c *Checker) store(tokenId string, revoked bool, now time.Time) {
+	if tokenId == "" || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, entry := range c.cache {
+		if now.After(entry.until) {
+			delete(c.cache, id)
+		}
+	}
+
+	c.cache[tokenId] = cacheEntry{
+		revoked: revoked,
+		until:   now.Add(c.ttl),
+	}
+}
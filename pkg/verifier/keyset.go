@@ -0,0 +1,103 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ei-jobs/auth-service/pkg/jwk"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// minRefetchInterval limits how often an unknown kid can trigger a JWKS
+// download, so tokens with made-up kids cannot flood the auth service.
+const minRefetchInterval = 10 * time.Second
+
+type KeySet interface {
+	Key(ctx context.Context, kid string) (jwk.Key, error)
+}
+
+// RemoteKeySet downloads the auth service's JWKS document over HTTP and
+// caches it for ttl. An unknown kid triggers an early refresh, which picks up
+// keys created by rotation before the cache would otherwise expire.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	set       jwk.Set
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string, client *http.Client, ttl time.Duration) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &RemoteKeySet{
+		url:    url,
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (k *RemoteKeySet) Key(ctx context.Context, kid string) (jwk.Key, error) {
+	const op = "verifier.Key"
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(k.fetchedAt) < k.ttl {
+		if key, ok := k.set.Find(kid); ok {
+			return key, nil
+		}
+	}
+
+	if now.Sub(k.fetchedAt) >= minRefetchInterval {
+		set, err := k.fetch(ctx)
+		if err != nil {
+			return jwk.Key{}, fmt.Errorf("%s: %w", op, err)
+		}
+		k.set = set
+		k.fetchedAt = now
+	}
+
+	key, ok := k.set.Find(kid)
+	if !ok {
+		return jwk.Key{}, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+	}
+
+	return key, nil
+}
+
+func (k *RemoteKeySet) fetch(ctx context.Context) (jwk.Set, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return jwk.Set{}, err
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return jwk.Set{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jwk.Set{}, fmt.Errorf("unexpected JWKS status %d", resp.StatusCode)
+	}
+
+	var set jwk.Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return jwk.Set{}, err
+	}
+
+	return set, nil
+}
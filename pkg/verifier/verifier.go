@@ -0,0 +1,243 @@
+// Package verifier lets other services check access tokens issued by the
+// auth service without calling it on every request.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrWrongAudience = errors.New("token issued for another app")
+	ErrTokenRevoked  = errors.New("token revoked")
+)
+
+type Claims struct {
+	UserId        int64
+	Phone         string
+	PhoneVerified bool
+	AppId         int32
+	SessionId     string
+	TokenId       string
+	Roles         []string
+	Permissions   []string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+func (c *Claims) HasRole(name string) bool {
+	return contains(c.Roles, name)
+}
+
+func (c *Claims) HasPermission(name string) bool {
+	return contains(c.Permissions, name)
+}
+
+// RevocationChecker reports whether a token that passed local verification
+// has since been revoked, for example by logout or a password change.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, token string, claims *Claims) (bool, error)
+}
+
+type Verifier struct {
+	appId      int32
+	secrets    [][]byte
+	keys       KeySet
+	leeway     time.Duration
+	revocation RevocationChecker
+}
+
+type Option func(*Verifier)
+
+// WithRevocationChecker makes Verify consult checker after the signature,
+// expiry and audience checks pass. Without it revoked tokens stay valid until
+// they expire.
+func WithRevocationChecker(checker RevocationChecker) Option {
+	return func(v *Verifier) {
+		v.revocation = checker
+	}
+}
+
+// WithPreviousSecrets also accepts tokens signed with secrets the app used
+// before its current one. Pass the previous secret for the grace period
+// after a secret rotation, so tokens issued before it keep verifying.
+func WithPreviousSecrets(secrets ...string) Option {
+	return func(v *Verifier) {
+		for _, secret := range secrets {
+			v.secrets = append(v.secrets, []byte(secret))
+		}
+	}
+}
+
+// WithLeeway tolerates clock skew between the auth service and the caller.
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// New returns a verifier for tokens signed with the app's HS256 secret.
+// Services that have to survive secret rotation without redeploying should
+// prefer NewWithKeySet.
+func New(appId int32, secret string, opts ...Option) *Verifier {
+	v := &Verifier{
+		appId:   appId,
+		secrets: [][]byte{[]byte(secret)},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// NewWithKeySet returns a verifier for asymmetrically signed tokens whose
+// public keys are looked up by kid in keys.
+func NewWithKeySet(appId int32, keys KeySet, opts ...Option) *Verifier {
+	v := &Verifier{
+		appId: appId,
+		keys:  keys,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	const op = "verifier.Verify"
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return v.verificationKey(ctx, token)
+	},
+		jwt.WithValidMethods(v.validMethods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%s: %w", op, ErrTokenExpired)
+		}
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	claims := claimsFromMap(token.Claims.(jwt.MapClaims))
+
+	if claims.AppId != v.appId {
+		return nil, fmt.Errorf("%s: %w", op, ErrWrongAudience)
+	}
+
+	if v.revocation != nil {
+		revoked, err := v.revocation.IsRevoked(ctx, tokenString, claims)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("%s: %w", op, ErrTokenRevoked)
+		}
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	if v.keys == nil {
+		if len(v.secrets) == 1 {
+			return v.secrets[0], nil
+		}
+
+		set := jwt.VerificationKeySet{}
+		for _, secret := range v.secrets {
+			set.Keys = append(set.Keys, secret)
+		}
+		return set, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.keys.Key(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Alg != "" && key.Alg != token.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+
+	return key.PublicKey()
+}
+
+func (v *Verifier) validMethods() []string {
+	if v.keys == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	return []string{
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodES256.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+	}
+}
+
+func claimsFromMap(m jwt.MapClaims) *Claims {
+	uid, _ := m["uid"].(float64)
+	appId, _ := m["app_id"].(float64)
+	iat, _ := m["iat"].(float64)
+	exp, _ := m["exp"].(float64)
+	phone, _ := m["phone"].(string)
+	phoneVerified, _ := m["phone_verified"].(bool)
+	sid, _ := m["sid"].(string)
+	jti, _ := m["jti"].(string)
+
+	return &Claims{
+		UserId:        int64(uid),
+		Phone:         phone,
+		PhoneVerified: phoneVerified,
+		AppId:         int32(appId),
+		SessionId:     sid,
+		TokenId:       jti,
+		Roles:         listClaim(m["roles"]),
+		Permissions:   listClaim(m["permissions"]),
+		IssuedAt:      time.Unix(int64(iat), 0),
+		ExpiresAt:     time.Unix(int64(exp), 0),
+	}
+}
+
+// listClaim reads roles and permissions, which the auth service encodes
+// either as arrays or, in compact mode, as space-separated strings.
+func listClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func contains(values []string, name string) bool {
+	for _, value := range values {
+		if value == name {
+			return true
+		}
+	}
+	return false
+}
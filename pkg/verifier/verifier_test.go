@@ -0,0 +1,88 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signHS256(t *testing.T, secret string, appId int32) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":    42,
+		"app_id": appId,
+		"exp":    time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	return token
+}
+
+func TestVerifyHS256Secrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		signedBy string
+		verifier *Verifier
+		wantErr  error
+	}{
+		{
+			name:     "current secret",
+			signedBy: "current",
+			verifier: New(1, "current"),
+		},
+		{
+			name:     "previous secret without option",
+			signedBy: "previous",
+			verifier: New(1, "current"),
+			wantErr:  ErrInvalidToken,
+		},
+		{
+			name:     "previous secret during grace period",
+			signedBy: "previous",
+			verifier: New(1, "current", WithPreviousSecrets("previous")),
+		},
+		{
+			name:     "current secret with previous configured",
+			signedBy: "current",
+			verifier: New(1, "current", WithPreviousSecrets("previous")),
+		},
+		{
+			name:     "unknown secret",
+			signedBy: "other",
+			verifier: New(1, "current", WithPreviousSecrets("previous")),
+			wantErr:  ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.verifier.Verify(context.Background(), signHS256(t, tt.signedBy, 1))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if claims.UserId != 42 {
+				t.Errorf("UserId = %d, want 42", claims.UserId)
+			}
+		})
+	}
+}
+
+func TestVerifyWrongAudience(t *testing.T) {
+	_, err := New(1, "current").Verify(context.Background(), signHS256(t, "current", 2))
+	if !errors.Is(err, ErrWrongAudience) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrWrongAudience)
+	}
+}